@@ -0,0 +1,346 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/lab47/labctl/types"
+)
+
+// targetProfiles returns the profiles a command operating on one or all
+// profiles applies to. Profiles are never created: asking for one that
+// doesn't exist is an error, while a missing current profile means there
+// are none to apply to.
+func targetProfiles(ctx context.Context, cfg *Config, all bool) ([]*Profile, error) {
+	if !all {
+		requested := globalOptions(ctx).Profile
+
+		name := requested
+		if name == "" {
+			name = cfg.CurrentProfile()
+		}
+
+		prof, ok := cfg.Lookup(name)
+		if !ok {
+			if requested != "" {
+				return nil, fmt.Errorf("unknown profile: %s", requested)
+			}
+
+			return nil, nil
+		}
+
+		return []*Profile{prof}, nil
+	}
+
+	var profs []*Profile
+
+	for _, name := range cfg.ProfileNames() {
+		prof, _ := cfg.Lookup(name)
+		profs = append(profs, prof)
+	}
+
+	return profs, nil
+}
+
+type identity struct {
+	Profile string `json:"profile"`
+	API     string `json:"api"`
+	*types.WhoAmIResponse
+
+	Error string `json:"error,omitempty"`
+}
+
+type identityList []*identity
+
+func (l identityList) WriteText(w io.Writer) error {
+	for _, id := range l {
+		fmt.Fprintf(w, "[profile %s]\n        api: %s\n", id.Profile, id.API)
+
+		if id.Error != "" {
+			fmt.Fprintf(w, "      error: %s\n", id.Error)
+			continue
+		}
+
+		expires := "never"
+		if id.ExpiresAt != nil {
+			expires = id.ExpiresAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "      email: %s\n namespaces: %s\n    expires: %s\n     scopes: %s\n",
+			id.Email,
+			strings.Join(id.Namespaces, ", "),
+			expires,
+			strings.Join(id.Scopes, ", "),
+		)
+	}
+
+	return nil
+}
+
+func (c *CLI) whoamiF(ctx context.Context, opts struct {
+	AllProfiles bool `long:"all-profiles" description:"show the identity of every profile"`
+}) (identityList, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	profs, err := targetProfiles(ctx, cfg, opts.AllProfiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profs) == 0 && !opts.AllProfiles {
+		return nil, errNotLoggedIn
+	}
+
+	var list identityList
+
+	for _, prof := range profs {
+		id := &identity{
+			Profile: prof.Name,
+			API:     prof.API(),
+		}
+
+		if prof.Token == "" {
+			if !opts.AllProfiles {
+				return nil, errNotLoggedIn
+			}
+
+			id.Error = "not logged in"
+			list = append(list, id)
+
+			continue
+		}
+
+		resp, err := apiClient(ctx, prof).WhoAmI(ctx)
+		if err != nil {
+			if !opts.AllProfiles {
+				return nil, err
+			}
+
+			id.Error = err.Error()
+		}
+
+		id.WhoAmIResponse = resp
+		list = append(list, id)
+	}
+
+	return list, nil
+}
+
+type logoutResult struct {
+	Profiles []string `json:"profiles"`
+}
+
+func (r *logoutResult) WriteText(w io.Writer) error {
+	if len(r.Profiles) == 0 {
+		_, err := fmt.Fprintln(w, "No profiles were logged in.")
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Logged out of: %s\n", strings.Join(r.Profiles, ", "))
+	return err
+}
+
+func (c *CLI) logoutF(ctx context.Context, opts struct {
+	AllProfiles bool `long:"all-profiles" description:"log out of every profile"`
+	Force       bool `short:"f" long:"force" description:"forget the token even if it can't be revoked on the server"`
+}) (*logoutResult, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	profs, err := targetProfiles(ctx, cfg, opts.AllProfiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		result = &logoutResult{}
+		failed error
+	)
+
+	for _, prof := range profs {
+		if prof.Token == "" {
+			continue
+		}
+
+		statusf(ctx, "Revoking token for profile %s...\n", prof.Name)
+
+		err = apiClient(ctx, prof).RevokeToken(ctx)
+		if err != nil {
+			// A token the server no longer accepts is as good as revoked.
+			if !opts.Force && !client.IsKind(err, client.KindUnauthenticated) {
+				failed = err
+				break
+			}
+
+			statusf(ctx, "=> Unable to revoke token, forgetting it anyway: %s\n", err)
+		}
+
+		prof.Token = ""
+		result.Profiles = append(result.Profiles, prof.Name)
+	}
+
+	// Save even on failure so that profiles logged out so far stay that way.
+	if len(result.Profiles) > 0 {
+		err = SaveConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if failed != nil {
+		return nil, failed
+	}
+
+	return result, nil
+}
+
+type passwordResult struct {
+	Profile   string `json:"profile"`
+	Email     string `json:"email"`
+	EmailSent bool   `json:"email_sent,omitempty"`
+}
+
+func (r *passwordResult) WriteText(w io.Writer) error {
+	if r.EmailSent {
+		_, err := fmt.Fprintf(w, "A password reset token was sent to %s.\nRun 'labctl account reset-password --token <token>' to choose a new password.\n", r.Email)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Password changed for %s (profile %s).\n", r.Email, r.Profile)
+	return err
+}
+
+func (c *CLI) changePasswordF(ctx context.Context, opts struct {
+	Email    string `short:"e" long:"email" description:"email address for account"`
+	Password string `short:"p" long:"password" description:"current password for account"`
+}) (*passwordResult, error) {
+	cfg, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Email == "" {
+		opts.Email = prof.Email
+	}
+
+	if opts.Email == "" {
+		return nil, fmt.Errorf("email (-e) is required")
+	}
+
+	if opts.Password == "" {
+		pass, err := readPassword("Enter current password: ")
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Password = pass
+	}
+
+	newPass, err := readNewPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	if newPass == opts.Password {
+		return nil, fmt.Errorf("new password must be different from the current one")
+	}
+
+	resp, err := apiClient(ctx, prof).ChangePassword(ctx, opts.Email, opts.Password, newPass)
+	if err != nil {
+		if client.IsKind(err, client.KindUnauthenticated) {
+			return nil, &client.Error{
+				Kind: client.KindUnauthenticated,
+				Err:  fmt.Errorf("current password is incorrect"),
+			}
+		}
+
+		return nil, err
+	}
+
+	// The server rotates the token when the password changes, invalidating
+	// the one we have.
+	if resp.Token != "" {
+		prof.Email = opts.Email
+		prof.Token = resp.Token
+
+		err = SaveConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &passwordResult{
+		Profile: prof.Name,
+		Email:   opts.Email,
+	}, nil
+}
+
+func (c *CLI) resetPasswordF(ctx context.Context, opts struct {
+	Email string `short:"e" long:"email" description:"email address for account"`
+	Token string `long:"token" description:"reset token from the password reset email"`
+}) (*passwordResult, error) {
+	cfg, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Email == "" {
+		opts.Email = prof.Email
+	}
+
+	api := apiClient(ctx, prof)
+
+	if opts.Token == "" {
+		if opts.Email == "" {
+			return nil, fmt.Errorf("email (-e) is required")
+		}
+
+		err = api.RequestPasswordReset(ctx, opts.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		return &passwordResult{
+			Profile:   prof.Name,
+			Email:     opts.Email,
+			EmailSent: true,
+		}, nil
+	}
+
+	newPass, err := readNewPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := api.ResetPassword(ctx, opts.Token, newPass)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Email != "" {
+		prof.Email = opts.Email
+	}
+
+	if resp.Token != "" {
+		prof.Token = resp.Token
+	}
+
+	err = SaveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &passwordResult{
+		Profile: prof.Name,
+		Email:   prof.Email,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const winClose = `<html>
+<body>
+<script>
+	window.close()
+</script>
+	<h4>
+	You may now close this window.
+	</h4>
+</body>
+</html>
+`
+
+// waitForCallback serves l until the browser is redirected back to it at
+// path, returning the form values of the first such request that accept
+// approves. Other requests, such as for a favicon or with the wrong state,
+// are answered and otherwise ignored. An empty path matches any path, for
+// flows that only register a port, and a nil accept approves any request.
+// If no request is approved in time it returns context.DeadlineExceeded, or
+// context.Canceled if ctx was canceled first, such as by Ctrl-C.
+func waitForCallback(ctx context.Context, l net.Listener, path string, timeout time.Duration, accept func(url.Values) bool) (url.Values, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	found := make(chan url.Values, 1)
+
+	h := &http.Server{
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/favicon.ico" || (path != "" && r.URL.Path != path) {
+				http.NotFound(rw, r)
+				return
+			}
+
+			err := r.ParseForm()
+			if err != nil {
+				http.Error(rw, "invalid request", http.StatusBadRequest)
+				return
+			}
+
+			if accept != nil && !accept(r.Form) {
+				http.Error(rw, "This request does not match the one labctl is waiting for and was ignored.", http.StatusBadRequest)
+				return
+			}
+
+			select {
+			case found <- r.Form:
+			default:
+			}
+
+			fmt.Fprint(rw, winClose)
+		}),
+	}
+
+	defer h.Shutdown(context.Background())
+
+	go h.Serve(l)
+
+	select {
+	case vals := <-found:
+		return vals, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -1,17 +1,16 @@
 package cli
 
 import (
+	"bufio"
 	"context"
-	"encoding/base64"
 	"fmt"
+	"io"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/lab47/labctl/types"
 	"github.com/mitchellh/cli"
 	"github.com/pkg/browser"
@@ -26,11 +25,6 @@ type CLI struct {
 func NewCLI(args []string) (*CLI, error) {
 	o := &CLI{}
 
-	base := os.Getenv("LAB47_API_BASE")
-	if base != "" {
-		baseURL = base
-	}
-
 	o.c = cli.NewCLI("labctl", "0.1.0")
 	o.c.Args = args
 	o.c.Commands = map[string]cli.CommandFactory{
@@ -48,6 +42,34 @@ func NewCLI(args []string) (*CLI, error) {
 				o.loginF,
 			), nil
 		},
+		"whoami": func() (cli.Command, error) {
+			return newCmd(
+				"whoami",
+				"show the identity of the current token",
+				o.whoamiF,
+			), nil
+		},
+		"logout": func() (cli.Command, error) {
+			return newCmd(
+				"logout",
+				"revoke the current token and forget it",
+				o.logoutF,
+			), nil
+		},
+		"account change-password": func() (cli.Command, error) {
+			return newCmd(
+				"change-password",
+				"change the password of the account",
+				o.changePasswordF,
+			), nil
+		},
+		"account reset-password": func() (cli.Command, error) {
+			return newCmd(
+				"reset-password",
+				"email a password reset token, or use one to set a new password",
+				o.resetPasswordF,
+			), nil
+		},
 		"namespaces": func() (cli.Command, error) {
 			return newCmd(
 				"namespaces",
@@ -55,6 +77,69 @@ func NewCLI(args []string) (*CLI, error) {
 				o.namespacesF,
 			), nil
 		},
+		"namespace create": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-create",
+				"create a new namespace",
+				o.createNamespaceF,
+			), nil
+		},
+		"namespace describe": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-describe",
+				"show the details of a namespace",
+				o.describeNamespaceF,
+			), nil
+		},
+		"namespace delete": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-delete",
+				"delete a namespace",
+				o.deleteNamespaceF,
+			), nil
+		},
+		"namespace set-metadata": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-set-metadata",
+				"set or remove (with key=) metadata on a namespace",
+				o.setNamespaceMetadataF,
+			), nil
+		},
+		"namespace members list": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-members-list",
+				"list the members of a namespace and their roles",
+				o.membersListF,
+			), nil
+		},
+		"namespace members invite": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-members-invite",
+				"invite someone to a namespace with a role",
+				o.membersInviteF,
+			), nil
+		},
+		"namespace members remove": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-members-remove",
+				"remove a member from a namespace",
+				o.membersRemoveF,
+			), nil
+		},
+		"namespace members set-role": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-members-set-role",
+				"change the role of a member of a namespace",
+				o.membersSetRoleF,
+			), nil
+		},
+		"namespace join": func() (cli.Command, error) {
+			return newCmd(
+				"namespace-join",
+				"accept an invitation to a namespace",
+				o.joinNamespaceF,
+			), nil
+		},
 		"machine-account create": func() (cli.Command, error) {
 			return newCmd(
 				"machine-account-create",
@@ -63,6 +148,34 @@ func NewCLI(args []string) (*CLI, error) {
 			), nil
 
 		},
+		"machine-account list": func() (cli.Command, error) {
+			return newCmd(
+				"machine-account-list",
+				"list the machine accounts in a namespace",
+				o.listMachineF,
+			), nil
+		},
+		"machine-account show": func() (cli.Command, error) {
+			return newCmd(
+				"machine-account-show",
+				"show the details of a machine account",
+				o.showMachineF,
+			), nil
+		},
+		"machine-account rotate": func() (cli.Command, error) {
+			return newCmd(
+				"machine-account-rotate",
+				"issue a new token for a machine account, invalidating the old one",
+				o.rotateMachineF,
+			), nil
+		},
+		"machine-account revoke": func() (cli.Command, error) {
+			return newCmd(
+				"machine-account-revoke",
+				"delete a machine account and its token",
+				o.revokeMachineF,
+			), nil
+		},
 		"credit add": func() (cli.Command, error) {
 			return newCmd(
 				"created-add",
@@ -84,6 +197,48 @@ func NewCLI(args []string) (*CLI, error) {
 				o.fulcioCert,
 			), nil
 		},
+		"profile list": func() (cli.Command, error) {
+			return newCmd(
+				"profile-list",
+				"list configured profiles",
+				o.profileListF,
+			), nil
+		},
+		"profile use": func() (cli.Command, error) {
+			return newCmd(
+				"profile-use",
+				"switch to (creating if needed) a profile",
+				o.profileUseF,
+			), nil
+		},
+		"profile show": func() (cli.Command, error) {
+			return newCmd(
+				"profile-show",
+				"show the settings of a profile",
+				o.profileShowF,
+			), nil
+		},
+		"profile delete": func() (cli.Command, error) {
+			return newCmd(
+				"profile-delete",
+				"delete a profile",
+				o.profileDeleteF,
+			), nil
+		},
+		"config secret-store": func() (cli.Command, error) {
+			return newCmd(
+				"secret-store",
+				"show or change where tokens are stored (plaintext, keyring, pass, file)",
+				o.secretStoreF,
+			), nil
+		},
+		"credential-helper": func() (cli.Command, error) {
+			return newCmd(
+				"credential-helper",
+				"docker credential helper backed by labctl profiles",
+				o.credentialHelperF,
+			), nil
+		},
 		"vcr create-repo": func() (cli.Command, error) {
 			return newCmd(
 				"create-repo",
@@ -98,6 +253,83 @@ func NewCLI(args []string) (*CLI, error) {
 				o.repoSettingsF,
 			), nil
 		},
+		"vcr repos": func() (cli.Command, error) {
+			return newCmd(
+				"repos",
+				"list repositories",
+				o.reposF,
+			), nil
+		},
+		"vcr describe-repo": func() (cli.Command, error) {
+			return newCmd(
+				"describe-repo",
+				"show the details of a repository",
+				o.describeRepoF,
+			), nil
+		},
+		"vcr delete-repo": func() (cli.Command, error) {
+			return newCmd(
+				"delete-repo",
+				"delete a repository and all of its tags",
+				o.deleteRepoF,
+			), nil
+		},
+		"vcr tags": func() (cli.Command, error) {
+			return newCmd(
+				"tags",
+				"list the tags of a repository",
+				o.tagsF,
+			), nil
+		},
+		"vcr untag": func() (cli.Command, error) {
+			return newCmd(
+				"untag",
+				"remove tags from a repository, leaving the manifests",
+				o.untagF,
+			), nil
+		},
+		"vcr delete-manifest": func() (cli.Command, error) {
+			return newCmd(
+				"delete-manifest",
+				"delete manifests, and every tag pointing at them, from a repository",
+				o.deleteManifestF,
+			), nil
+		},
+		"vcr retention preview": func() (cli.Command, error) {
+			return newCmd(
+				"retention-preview",
+				"show what a retention policy would remove from a repository",
+				o.retentionPreviewF,
+			), nil
+		},
+		"vcr push": func() (cli.Command, error) {
+			return newCmd(
+				"push",
+				"push an image tarball or OCI layout to a registry",
+				o.pushF,
+			), nil
+		},
+		"vcr mirror sync": func() (cli.Command, error) {
+			return newCmd(
+				"sync",
+				"copy the upstream images listed in a mirror file that are missing or out of date",
+				o.mirrorSyncF,
+			), nil
+		},
+		"vcr pull": func() (cli.Command, error) {
+			return newCmd(
+				"pull",
+				"pull an image into an OCI layout directory",
+				o.pullF,
+			), nil
+		},
+		"vcr copy": func() (cli.Command, error) {
+			return newCmd(
+				"copy",
+				"copy an image, all of its platforms and its signatures to another repository or registry",
+				o.copyF,
+			), nil
+		},
 		"vcr docker-login": func() (cli.Command, error) {
 			return newCmd(
 				"docker-login",
@@ -105,6 +337,13 @@ func NewCLI(args []string) (*CLI, error) {
 				o.dockerLoginF,
 			), nil
 		},
+		"vcr registry-login": func() (cli.Command, error) {
+			return newCmd(
+				"registry-login",
+				"write vcr.pub credentials for docker, podman, containerd or a file",
+				o.registryLoginF,
+			), nil
+		},
 		"vcr kubernetes-secret": func() (cli.Command, error) {
 			return newCmd(
 				"kubernetes-secret",
@@ -142,152 +381,236 @@ func (c *CLI) Run() (int, error) {
 	return c.c.Run()
 }
 
-func (c *CLI) loginF(ctx context.Context, opts struct {
-	Email    string `short:"e" long:"email" description:"email address for account"`
-	Password string `short:"p" long:"password" description:"password for account"`
-}) error {
-	if opts.Email == "" {
-		return fmt.Errorf("email (-e) is required")
+// readPassword prompts for a password on the terminal.
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading password")
 	}
 
-	if opts.Password == "" {
-		fmt.Print("Enter password: ")
-		data, err := term.ReadPassword(int(os.Stdin.Fd()))
-		if err != nil {
-			return errors.Wrapf(err, "error reading password")
-		}
+	return string(data), nil
+}
 
-		opts.Password = string(data)
+// readLine prompts for a line of input on the terminal.
+func readLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
 
-		fmt.Println()
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", errors.Wrapf(err, "error reading input")
 	}
 
-	var tv struct {
-		Token string `json:"token"`
+	return strings.TrimSpace(line), nil
+}
+
+// readNewPassword prompts for a new password twice, making sure both match.
+func readNewPassword() (string, error) {
+	pass, err := readPassword("Enter new password: ")
+	if err != nil {
+		return "", err
 	}
 
-	hdrs := http.Header{}
-	setAuthorization(hdrs, opts.Email, opts.Password)
+	if pass == "" {
+		return "", fmt.Errorf("password can not be empty")
+	}
 
-	err := perform(ctx, "GET", "/api/v1/token", hdrs, nil, &tv)
+	confirm, err := readPassword("Confirm new password: ")
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	cfg, err := LoadConfig()
-	if err != nil {
-		return err
+	if pass != confirm {
+		return "", fmt.Errorf("passwords do not match")
 	}
 
-	cfg.Account.Email = opts.Email
-	cfg.Account.Token = tv.Token
+	return pass, nil
+}
 
-	err = SaveConfig(cfg)
-	if err != nil {
+type loginResult struct {
+	Profile string `json:"profile"`
+	Email   string `json:"email"`
+	API     string `json:"api"`
+
+	// Subject and ExpiresAt are set for tokens obtained by OIDC exchange.
+	Subject   string     `json:"subject,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	created bool
+}
+
+func (r *loginResult) WriteText(w io.Writer) error {
+	if r.created {
+		_, err := fmt.Fprintf(w, "Account created and logged into!\n")
 		return err
 	}
 
-	fmt.Printf("Logged into svc.lab47.dev!\n")
+	if r.Subject != "" {
+		expires := "unknown"
+		if r.ExpiresAt != nil {
+			expires = r.ExpiresAt.Format(time.RFC3339)
+		}
 
-	return nil
+		_, err := fmt.Fprintf(w, "Logged into %s as %s in profile %s, token expires %s\n", r.API, r.Subject, r.Profile, expires)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Logged into %s as profile %s!\n", r.API, r.Profile)
+	return err
 }
 
-func (c *CLI) createF(ctx context.Context, opts struct {
-	Email     string `short:"e" long:"email" description:"email address for account"`
-	Namespace string `short:"n" long:"namespace" description:"initial namespace to reserve"`
-	Password  string `short:"p" long:"password" description:"password for account"`
-}) error {
-	if opts.Email == "" {
-		return fmt.Errorf("email (-e) is required")
+func (c *CLI) loginF(ctx context.Context, opts struct {
+	Email      string `short:"e" long:"email" description:"email address for account"`
+	Password   string `short:"p" long:"password" description:"password for account"`
+	Web        bool   `long:"web" description:"log in through the browser"`
+	DeviceCode bool   `long:"device-code" description:"log in by approving a code from another device"`
+
+	OIDCTokenFile string   `long:"oidc-token-file" description:"log in by exchanging the OIDC ID token in this file"`
+	OIDCTokenEnv  string   `long:"oidc-token-env" description:"log in by exchanging the OIDC ID token in this environment variable"`
+	OIDCAudience  string   `long:"oidc-audience" description:"audience the OIDC token must be issued for"`
+	OIDCIssuer    []string `long:"oidc-issuer" description:"issuer the OIDC token may come from, may be repeated (default: the GitHub Actions and GitLab issuers)"`
+	Namespace     string   `short:"n" long:"namespace" description:"namespace whose trust policy allows the OIDC token"`
+}) (*loginResult, error) {
+	oidcLogin := opts.OIDCTokenFile != "" || opts.OIDCTokenEnv != ""
+
+	modes := 0
+	for _, set := range []bool{opts.Web, opts.DeviceCode, oidcLogin} {
+		if set {
+			modes++
+		}
 	}
 
-	if opts.Namespace == "" {
-		return fmt.Errorf("namespace (-n) is required")
+	if modes > 1 || (opts.OIDCTokenFile != "" && opts.OIDCTokenEnv != "") {
+		return nil, fmt.Errorf("only one of --web, --device-code, --oidc-token-file or --oidc-token-env can be used")
 	}
 
-	if opts.Password == "" {
-		fmt.Print("Enter password: ")
-		data, err := term.ReadPassword(int(os.Stdin.Fd()))
-		if err != nil {
-			return errors.Wrapf(err, "error reading password")
-		}
+	if oidcLogin {
+		return c.oidcLogin(ctx, oidcLoginOptions{
+			Namespace: opts.Namespace,
+			File:      opts.OIDCTokenFile,
+			Env:       opts.OIDCTokenEnv,
+			Audience:  opts.OIDCAudience,
+			Issuers:   opts.OIDCIssuer,
+		})
+	}
 
-		opts.Password = string(data)
+	if opts.Web || opts.DeviceCode {
+		return c.oauthLogin(ctx, opts.DeviceCode)
+	}
 
-		fmt.Println()
+	if opts.Email == "" {
+		return nil, fmt.Errorf("email (-e) is required")
 	}
 
-	fmt.Println("Creating account...")
+	if opts.Password == "" {
+		pass, err := readPassword("Enter password: ")
+		if err != nil {
+			return nil, err
+		}
 
-	var tv struct {
-		Token string `json:"token"`
+		opts.Password = pass
 	}
 
-	err := Post(ctx, "/api/v1/account", map[string]string{
-		"email":     opts.Email,
-		"namespace": opts.Namespace,
-		"password":  opts.Password,
-	}, &tv)
+	cfg, prof, err := loadProfile(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	cfg, err := LoadConfig()
+	tv, err := apiClient(ctx, prof).Login(ctx, opts.Email, opts.Password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	cfg.Account.Email = opts.Email
-	cfg.Account.Token = tv.Token
+	prof.Email = opts.Email
+	prof.Token = tv.Token
 
 	err = SaveConfig(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("Account created and logged into!\n")
-
-	return nil
+	return &loginResult{
+		Profile: prof.Name,
+		Email:   prof.Email,
+		API:     prof.API(),
+	}, nil
 }
 
-func (c *CLI) createMachineF(ctx context.Context, opts struct {
-	Namespace   string `short:"n" long:"namespace" description:"initial namespace to reserve"`
-	Name        string `long:"name" description:"name for machine account"`
-	Description string `short:"d" long:"description" description:"description of machine account"`
-	Write       bool   `long:"enable-write" description:"allow the account to have write access"`
-}) error {
+func (c *CLI) createF(ctx context.Context, opts struct {
+	Email     string `short:"e" long:"email" description:"email address for account"`
+	Namespace string `short:"n" long:"namespace" description:"initial namespace to reserve"`
+	Password  string `short:"p" long:"password" description:"password for account"`
+}) (*loginResult, error) {
+	if opts.Email == "" {
+		return nil, fmt.Errorf("email (-e) is required")
+	}
+
 	if opts.Namespace == "" {
-		return fmt.Errorf("namespace (-n) is required")
+		return nil, fmt.Errorf("namespace (-n) is required")
 	}
 
-	cfg, err := LoadConfig()
-	if err != nil {
-		return err
+	if opts.Password == "" {
+		pass, err := readPassword("Enter password: ")
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Password = pass
 	}
 
-	name := opts.Name
-	if name == "" {
-		name = fmt.Sprintf("machine-%s", uuid.New().String())
+	cfg, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Creating machine account '%s'...\n", name)
+	statusf(ctx, "Creating account...\n")
 
-	var tv types.MachineAccountCreateResponse
+	tv, err := apiClient(ctx, prof).CreateAccount(ctx, &types.AccountInfo{
+		Email:     opts.Email,
+		Namespace: opts.Namespace,
+		Password:  opts.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	path := fmt.Sprintf("/api/v1/namespace/%s/machine-account", opts.Namespace)
+	prof.Email = opts.Email
+	prof.Token = tv.Token
 
-	err = TokenPut(ctx, cfg.Account.Token, path, &types.MachineAccountCreateRequest{
-		Name:        name,
-		Description: opts.Description,
-		Write:       opts.Write,
-	}, &tv)
+	err = SaveConfig(cfg)
 	if err != nil {
+		return nil, err
+	}
+
+	return &loginResult{
+		Profile: prof.Name,
+		Email:   prof.Email,
+		API:     prof.API(),
+		created: true,
+	}, nil
+}
+
+type repoResult struct {
+	Name     string                   `json:"name"`
+	Settings *types.RepoSettingsApply `json:"settings,omitempty"`
+	Deleted  bool                     `json:"deleted,omitempty"`
+}
+
+func (r *repoResult) WriteText(w io.Writer) error {
+	if r.Deleted {
+		_, err := fmt.Fprintf(w, "Repository deleted: %s\n", r.Name)
 		return err
 	}
 
-	fmt.Printf("Machine account created!\nToken for account: %s\n", tv.Token)
+	if r.Settings != nil {
+		_, err := fmt.Fprintf(w, "Updated %s!\n", r.Name)
+		return err
+	}
 
-	return nil
+	_, err := fmt.Fprintf(w, "Repository created: %s\n", r.Name)
+	return err
 }
 
 func (c *CLI) createRepoF(ctx context.Context, opts struct {
@@ -295,224 +618,299 @@ func (c *CLI) createRepoF(ctx context.Context, opts struct {
 	Pos       struct {
 		Name string `positional-arg-name:"name"`
 	} `positional-args:"yes"`
-}) error {
-	cfg, err := LoadConfig()
+}) (*repoResult, error) {
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "error loading configuration")
+		return nil, err
 	}
 
-	if cfg.Account.Token == "" {
-		return fmt.Errorf("Please login in first")
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
 	}
 
 	if opts.Pos.Name == "" {
-		return fmt.Errorf("requires repository name as argument")
+		return nil, fmt.Errorf("requires repository name as argument")
 	}
 
 	if strings.Count(opts.Pos.Name, "/") != 1 {
-		return fmt.Errorf("name must be in namespace/repo format")
+		return nil, fmt.Errorf("name must be in namespace/repo format")
 	}
 
-	fmt.Println("Creating repository...")
+	statusf(ctx, "Creating repository...\n")
 
 	fullName := opts.Pos.Name
 
-	path := fmt.Sprintf("/vcr/v1/repo/%s", fullName)
-
-	err = TokenPost(ctx, cfg.Account.Token, path, nil, nil)
+	err = apiClient(ctx, prof).CreateRepo(ctx, fullName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("Repository created: %s\n", fullName)
-
-	return nil
+	return &repoResult{Name: fullName}, nil
 }
 
 func (c *CLI) repoSettingsF(ctx context.Context, opts struct {
 	Public  *bool `short:"P" long:"public" description:"change the repos to public"`
 	Private *bool `short:"R" long:"private" description:"change the repos to private"`
 
+	Retention      retentionOptions `group:"Retention Options"`
+	ClearRetention bool             `long:"clear-retention" description:"remove the retention policy, keeping everything"`
+
+	Immutable immutableOptions `group:"Immutability Options"`
+
 	Pos struct {
 		Name string `positional-arg-name:"name"`
 	} `positional-args:"yes"`
-}) error {
-	cfg, err := LoadConfig()
+}) (*repoResult, error) {
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "error loading configuration")
+		return nil, err
 	}
 
-	if cfg.Account.Token == "" {
-		return fmt.Errorf("Please login in first")
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
 	}
 
 	if opts.Pos.Name == "" {
-		return fmt.Errorf("requires repository name as argument")
+		return nil, fmt.Errorf("requires repository name as argument")
 	}
 
 	if strings.Count(opts.Pos.Name, "/") != 1 {
-		return fmt.Errorf("name must be in namespace/repo format")
+		return nil, fmt.Errorf("name must be in namespace/repo format")
 	}
 
-	fmt.Println("Updating repository settings...")
+	statusf(ctx, "Updating repository settings...\n")
 
 	fullName := opts.Pos.Name
 
-	path := fmt.Sprintf("/vcr/v1/repo/%s/update-settings", fullName)
-
 	var settings types.RepoSettingsApply
 
 	if opts.Private != nil {
 		if opts.Public != nil {
-			return errors.New("Set either -P or -R, not both")
+			return nil, errors.New("Set either -P or -R, not both")
 		}
 
 		pub := false
 
 		settings.Public = &pub
-		fmt.Printf("=> Setting visibility to private\n")
+		statusf(ctx, "=> Setting visibility to private\n")
 	} else if opts.Public != nil {
 		settings.Public = opts.Public
-		fmt.Printf("=> Setting visibility to public\n")
+		statusf(ctx, "=> Setting visibility to public\n")
 	}
 
-	err = TokenPut(ctx, cfg.Account.Token, path, settings, nil)
+	policy, err := opts.Retention.policy()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("Updated %s!\n", fullName)
+	if opts.ClearRetention {
+		if policy != nil {
+			return nil, errors.New("--clear-retention can't be combined with other retention options")
+		}
 
-	return nil
+		settings.Retention = &types.RetentionPolicy{}
+		statusf(ctx, "=> Removing retention policy\n")
+	} else if policy != nil {
+		settings.Retention = policy
+		statusf(ctx, "=> Setting retention policy: %s\n", describePolicy(policy))
+	}
+
+	settings.ImmutableTags, err = opts.Immutable.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.ImmutableTags != nil {
+		statusf(ctx, "=> Setting immutable tags: %s\n", describeImmutable(settings.ImmutableTags))
+	}
+
+	err = apiClient(ctx, prof).UpdateRepoSettings(ctx, fullName, &settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repoResult{Name: fullName, Settings: &settings}, nil
+}
+
+type dockerLoginResult struct {
+	Server string `json:"server"`
+	Config string `json:"config,omitempty"`
+	Helper string `json:"helper,omitempty"`
+}
+
+func (r *dockerLoginResult) WriteText(w io.Writer) error {
+	if r.Helper == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "Configured docker to use docker-credential-%s for %s in %s\n", r.Helper, r.Server, r.Config)
+	return err
 }
 
 func (c *CLI) dockerLoginF(ctx context.Context, opts struct {
-}) error {
-	cfg, err := LoadConfig()
+	Helper bool `long:"helper" description:"configure docker to use labctl as the credential helper instead of storing the token"`
+}) (*dockerLoginResult, error) {
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "error loading configuration")
+		return nil, err
 	}
 
-	if cfg.Account.Token == "" {
-		return fmt.Errorf("Please login first")
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
 	}
 
-	server := currentServer()
+	server := prof.RegistryHost()
+
+	if opts.Helper {
+		path, err := dockerConfigPath()
+		if err != nil {
+			return nil, err
+		}
 
-	fmt.Printf("Logging local docker into %s...\n", server)
+		af, err := loadAuthFile(path)
+		if err != nil {
+			return nil, err
+		}
 
-	cmd := exec.CommandContext(ctx, "docker", "login", "-u", "cytoken", "-p", cfg.Account.Token, server)
+		af.setCredHelper(server, "labctl")
+
+		err = af.save(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return &dockerLoginResult{
+			Server: server,
+			Config: path,
+			Helper: "labctl",
+		}, nil
+	}
+
+	statusf(ctx, "Logging local docker into %s...\n", server)
+
+	// The token goes through stdin so it doesn't show up in the process list.
+	cmd := exec.CommandContext(ctx, "docker", "login", "-u", tokenUser, "--password-stdin", server)
+	cmd.Stdin = strings.NewReader(prof.Token)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	return cmd.Run()
+	if structuredOutput(ctx) {
+		cmd.Stdout = os.Stderr
+	}
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dockerLoginResult{Server: server}, nil
+}
+
+type k8sSecret struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+	Type string            `json:"type"`
 }
 
 func (c *CLI) k8SecretF(ctx context.Context, opts struct {
-}) error {
-	cfg, err := LoadConfig()
+}) (*k8sSecret, error) {
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "error loading configuration")
+		return nil, err
 	}
 
-	if cfg.Account.Token == "" {
-		return fmt.Errorf("Please login first")
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
 	}
 
-	basic := base64.StdEncoding.EncodeToString([]byte("cytoken:" + cfg.Account.Token))
-
-	authConfg := fmt.Sprintf(`{
-    "auths": {
-        "https://vcr.pub": {
-            "auth": "%s"
-        }
-    }
+	return newK8sSecret("vcr-pub", prof.RegistryHost(), tokenUser, prof.Token), nil
 }
-`, basic)
 
-	encoded := base64.StdEncoding.EncodeToString([]byte(authConfg))
+type namespaceList types.ListNamespaces
 
-	str := fmt.Sprintf(`apiVersion: v1
-kind: Secret
-metadata:
-  name: vcr-pub
-data:
-  .dockerconfigjson: %s
-type: kubernetes.io/dockerconfigjson`, encoded)
+func (l *namespaceList) WriteText(w io.Writer) error {
+	for _, ns := range l.Namespaces {
+		fmt.Fprintf(w, "[namespace]\n   name: %s\ncredits: $%s\n  repos:\n", ns.Name, ns.Credit)
 
-	fmt.Println(str)
+		for _, re := range ns.Repos {
+			fmt.Fprintf(w, "  - name: %s\n  - created_at: %s\n",
+				re.Name,
+				re.CreatedAt.Format(time.RFC3339),
+			)
+		}
+	}
 
 	return nil
 }
 
 func (c *CLI) namespacesF(ctx context.Context, opts struct {
-}) error {
-	cfg, err := LoadConfig()
+}) (*namespaceList, error) {
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return errors.Wrap(err, "error loading configuration")
+		return nil, err
 	}
 
-	if cfg.Account.Token == "" {
-		return fmt.Errorf("Please login first")
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
 	}
 
-	var ln types.ListNamespaces
-
-	err = TokenGet(ctx, cfg.Account.Token, "/api/v1/namespaces", &ln)
+	ln, err := apiClient(ctx, prof).Namespaces(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, ns := range ln.Namespaces {
-		fmt.Printf("[namespace]\n   name: %s\ncredits: $%s\n  repos:\n", ns.Name, ns.Credit)
+	return (*namespaceList)(ln), nil
+}
+
+type creditResult struct {
+	Namespace  string `json:"namespace"`
+	PaymentURL string `json:"payment_url"`
+	Status     string `json:"status"`
+	Balance    string `json:"balance,omitempty"`
+}
 
-		for _, re := range ns.Repos {
-			fmt.Printf("  - name: %s\n  - created_at: %s\n",
-				re.Name,
-				re.CreatedAt.Format(time.RFC3339),
-			)
-		}
+func (r *creditResult) WriteText(w io.Writer) error {
+	switch r.Status {
+	case "timeout":
+		fmt.Fprintln(w, "Timed out waiting for signal of successful payment.")
+		fmt.Fprintln(w, "Credits may by added anyway, check `labctl namespaces`.")
+	case "pending":
+		fmt.Fprintln(w, "Use payment screen to complete payment and credits will be added to account.")
+	case "success":
+		fmt.Fprintf(w, "Credits added! Current balance: %s\n", r.Balance)
+	case "cancel":
+		fmt.Fprintln(w, "Payment canceled, no credits added.")
 	}
 
 	return nil
 }
 
-const winClose = `<html>
-<body>
-<script>
-	window.close()
-</script>
-	<h4>
-	You may now close this window.
-	</h4>
-</body>
-</html>
-`
-
 func (c *CLI) creditAddF(ctx context.Context, opts struct {
 	Namespace string `short:"n" long:"namespace" description:"initial namespace to reserve"`
 	Dollars   int64  `short:"d" long:"credit" description:"how many USD to add in credits"`
-}) error {
-	cfg, err := LoadConfig()
+}) (*creditResult, error) {
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "error loading configuration")
+		return nil, err
 	}
 
-	if cfg.Account.Token == "" {
-		return fmt.Errorf("Please login in first")
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
 	}
 
 	if opts.Namespace == "" {
-		return fmt.Errorf("name of namespace required")
+		return nil, fmt.Errorf("name of namespace required")
 	}
 
 	if opts.Dollars == 0 {
-		return fmt.Errorf("number of US Dollars to add to namespace required")
+		return nil, fmt.Errorf("number of US Dollars to add to namespace required")
 	}
 
-	fmt.Printf("Requesting $%d USD to namespace %s...\n", opts.Dollars, opts.Namespace)
-
-	path := "/api/v1/credit/add"
+	statusf(ctx, "Requesting $%d USD to namespace %s...\n", opts.Dollars, opts.Namespace)
 
 	req := &types.CreditAddRequest{
 		Namespace: opts.Namespace,
@@ -524,60 +922,43 @@ func (c *CLI) creditAddF(ctx context.Context, opts struct {
 		req.LocalPort = l.Addr().(*net.TCPAddr).Port
 	}
 
-	var resp types.CreditAddResponse
-
-	err = TokenPut(ctx, cfg.Account.Token, path, req, &resp)
+	resp, err := apiClient(ctx, prof).AddCredit(ctx, req)
 	if err != nil {
-		return err
+		return nil, permissionError(err, "adding credit", opts.Namespace, "billing", "admin")
+	}
+
+	result := &creditResult{
+		Namespace:  opts.Namespace,
+		PaymentURL: resp.URL,
+		Status:     "pending",
 	}
 
-	fmt.Println("Opening browser to enter payment information!")
+	statusf(ctx, "Opening browser to enter payment information!\n")
 
 	err = browser.OpenURL(resp.URL)
 	if err != nil {
-		fmt.Printf("Error opening browser. Please go to:\n%s\n", resp.URL)
-		return nil
+		statusf(ctx, "Error opening browser. Please go to:\n%s\n", resp.URL)
+		return result, nil
 	}
 
 	if l == nil {
-		fmt.Println("Use payment screen to complete payment and credits will be added to account.")
-		return nil
+		return result, nil
 	}
 
-	var (
-		status  string
-		balance string
-	)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
+	statusf(ctx, "Waiting for payment to complete...\n")
 
-	h := &http.Server{
-		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-			status = r.FormValue("status")
-			balance = r.FormValue("balance")
-
-			fmt.Fprintf(rw, winClose)
-			cancel()
-		}),
+	// The payment flow only registers a port, so any path is the callback.
+	vals, err := waitForCallback(ctx, l, "", 120*time.Second, nil)
+	if err == context.Canceled {
+		return nil, fmt.Errorf("stopped waiting for the payment, credits may be added anyway, check `labctl namespaces`")
 	}
 
-	defer h.Shutdown(context.Background())
-
-	fmt.Println("Waiting for payment to complete...")
-	go h.Serve(l)
+	result.Status = vals.Get("status")
+	result.Balance = vals.Get("balance")
 
-	<-ctx.Done()
-
-	switch status {
-	case "":
-		fmt.Println("Timed out waiting for signal of successful payment.")
-		fmt.Println("Credits may by added anyway, check `labctl namespaces`.")
-	case "success":
-		fmt.Printf("Credits added! Current balance: %s\n", balance)
-	case "cancel":
-		fmt.Println("Payment canceled, no credits added.")
+	if result.Status == "" {
+		result.Status = "timeout"
 	}
 
-	return nil
+	return result, nil
 }
@@ -7,17 +7,48 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"golang.org/x/sys/unix"
 )
 
+// GlobalOptions are the options available on every command.
+type GlobalOptions struct {
+	Profile string        `long:"profile" env:"LAB47_PROFILE" description:"name of the configuration profile to use"`
+	Timeout time.Duration `long:"timeout" env:"LAB47_TIMEOUT" default:"30s" description:"deadline for each API request and each read of a manifest, config or signature; other registry requests must start being answered within it, but pushed and pulled data isn't limited; 0 to disable"`
+	Output  string        `short:"o" long:"output" env:"LAB47_OUTPUT" default:"text" description:"output format: text, json, yaml or template=<go template>"`
+	Debug   bool          `long:"debug" env:"LAB47_DEBUG" description:"show stack traces for errors"`
+}
+
+type globalsKey struct{}
+
+// globalOptions returns the global options the running command was invoked
+// with.
+func globalOptions(ctx context.Context) *GlobalOptions {
+	if g, ok := ctx.Value(globalsKey{}).(*GlobalOptions); ok {
+		return g
+	}
+
+	return &GlobalOptions{}
+}
+
+// exitCode is returned by commands that have already reported their failure,
+// or whose result reports it, and only need the process to exit with the
+// given code. A result returned alongside it is still rendered.
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
 type Cmd struct {
 	syn, name string
 	f         reflect.Value
 
-	opts   reflect.Value
-	parser *flags.Parser
+	opts    reflect.Value
+	globals GlobalOptions
+	parser  *flags.Parser
 }
 
 func newCmd(name, syn string, f interface{}) *Cmd {
@@ -33,8 +64,8 @@ func newCmd(name, syn string, f interface{}) *Cmd {
 		panic("must provide two arguments only")
 	}
 
-	if rt.NumOut() != 1 {
-		panic("must return one argument only")
+	if rt.NumOut() != 1 && rt.NumOut() != 2 {
+		panic("must return an error, or a result and an error")
 	}
 
 	in := rt.In(1)
@@ -54,13 +85,20 @@ func newCmd(name, syn string, f interface{}) *Cmd {
 		panic(err)
 	}
 
-	return &Cmd{
+	cmd := &Cmd{
 		syn:    syn,
 		name:   name,
 		f:      rv,
 		opts:   sv,
 		parser: parser,
 	}
+
+	_, err = parser.AddGroup("Global Options", "", &cmd.globals)
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
 }
 
 func (w *Cmd) Help() string {
@@ -76,24 +114,65 @@ func (w *Cmd) Synopsis() string {
 func (w *Cmd) Run(args []string) int {
 	_, err := w.parser.ParseArgs(args)
 	if err != nil {
-		return 1
+		if fe, ok := err.(*flags.Error); ok && fe.Type == flags.ErrHelp {
+			return ExitOK
+		}
+
+		return ExitUsage
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	ctx = context.WithValue(ctx, globalsKey{}, &w.globals)
+
 	cancelOnSignal(cancel, os.Interrupt, unix.SIGQUIT, unix.SIGTERM)
 
+	out, err := newOutput(w.globals.Output)
+	if err != nil {
+		fmt.Printf("! Error: %s\n", err)
+		return ExitUsage
+	}
+
 	rets := w.f.Call([]reflect.Value{reflect.ValueOf(ctx), w.opts.Elem()})
 
-	if err, ok := rets[0].Interface().(error); ok {
+	if err, ok := rets[len(rets)-1].Interface().(error); ok {
+		if ec, ok := err.(exitCode); ok {
+			if len(rets) == 2 && !rets[0].IsNil() {
+				if err := out.render(os.Stdout, rets[0].Interface()); err != nil {
+					w.printError(err)
+				}
+			}
+
+			return int(ec)
+		}
+
 		if err != nil {
-			fmt.Printf("! Error: %+v\n", err)
-			return 1
+			w.printError(err)
+			return exitCodeFor(err)
 		}
 	}
 
-	return 0
+	if len(rets) == 2 {
+		err = out.render(os.Stdout, rets[0].Interface())
+		if err != nil {
+			w.printError(err)
+			return ExitError
+		}
+	}
+
+	return ExitOK
+}
+
+// printError reports err to the user, including the stack trace only when
+// --debug is passed.
+func (w *Cmd) printError(err error) {
+	if w.globals.Debug {
+		fmt.Printf("! Error: %+v\n", err)
+		return
+	}
+
+	fmt.Printf("! Error: %v\n", err)
 }
 
 func cancelOnSignal(cancel func(), signals ...os.Signal) {
@@ -1,34 +1,320 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/BurntSushi/toml"
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/lab47/labctl/pkg/retry"
 	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBase     = client.DefaultBaseURL
+	defaultRegistry = "vcr.pub"
+	defaultProfile  = "default"
 )
 
 type accountInfo struct {
-	Email string `toml:"email"`
-	Token string `toml:"token"`
+	Email string `toml:"email,omitempty"`
+	Token string `toml:"token,omitempty"`
+}
+
+// Profile is a named identity, holding the endpoints to talk to and the
+// credentials to use with them.
+type Profile struct {
+	Name string `toml:"-"`
+
+	APIBase  string `toml:"api_base,omitempty"`
+	Registry string `toml:"registry,omitempty"`
+	Email    string `toml:"email,omitempty"`
+	Token    string `toml:"token,omitempty"`
+
+	// Retries is how many times failed requests are retried, nil for the
+	// default.
+	Retries *int `toml:"retries,omitempty"`
+}
+
+// API returns the base URL of the lab47 API for the profile. LAB47_API_BASE
+// is used when the profile doesn't configure one.
+func (p *Profile) API() string {
+	if p.APIBase != "" {
+		return p.APIBase
+	}
+
+	if base := os.Getenv("LAB47_API_BASE"); base != "" {
+		return base
+	}
+
+	return defaultBase
+}
+
+// RegistryHost returns the host of the OCI registry associated with the
+// profile. An API base that isn't a valid URL, which can only come from a
+// hand edited config, falls back to the default registry.
+func (p *Profile) RegistryHost() string {
+	if p.Registry != "" {
+		return p.Registry
+	}
+
+	base := p.API()
+
+	if base == defaultBase {
+		return defaultRegistry
+	}
+
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return defaultRegistry
+	}
+
+	return u.Host
+}
+
+// checkAPIBase makes sure base is an absolute http or https URL.
+func checkAPIBase(base string) error {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid API base %q, expected a URL such as https://api.example.com", base)
+	}
+
+	return nil
+}
+
+// transport returns the round tripper for requests made with the profile,
+// retrying transient failures.
+func (p *Profile) transport() http.RoundTripper {
+	return retry.NewTransport(baseTransport, p.retries())
+}
+
+func (p *Profile) retries() int {
+	if p.Retries != nil {
+		return *p.Retries
+	}
+
+	return retry.DefaultRetries
+}
+
+func (p *Profile) httpClient() *http.Client {
+	return &http.Client{Transport: p.transport()}
 }
 
 type Config struct {
-	Account accountInfo `toml:"account"`
+	// Account is the pre-profile location of the credentials. It's only read
+	// so that it can be migrated into the default profile.
+	Account *accountInfo `toml:"account,omitempty"`
+
+	Current  string              `toml:"current_profile,omitempty"`
+	Profiles map[string]*Profile `toml:"profiles,omitempty"`
+
+	// SecretStore names the backend holding the profile tokens. When
+	// "plaintext", tokens are kept in the configuration file. When empty,
+	// the keyring is picked if it's available and recorded here, otherwise
+	// tokens are kept in plaintext.
+	SecretStore string `toml:"secret_store,omitempty"`
+
+	store     SecretStore
+	storeKind string
+	stored    map[string]bool
 }
 
-const defaultConfigDir = "~/.config/lab47"
+// Profile returns the profile with the given name, creating it if it does
+// not exist yet. An empty name selects the current profile.
+func (c *Config) Profile(name string) *Profile {
+	if name == "" {
+		name = c.CurrentProfile()
+	}
 
-func LoadConfig() (*Config, error) {
-	var cfg Config
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Profile{}
+	}
+
+	prof, ok := c.Profiles[name]
+	if !ok {
+		prof = &Profile{}
+		c.Profiles[name] = prof
+	}
+
+	prof.Name = name
+
+	return prof
+}
+
+// Lookup returns the profile with the given name, if there is one.
+func (c *Config) Lookup(name string) (*Profile, bool) {
+	prof, ok := c.Profiles[name]
+	if ok {
+		prof.Name = name
+	}
+
+	return prof, ok
+}
+
+// CurrentProfile returns the name of the profile used when none is requested
+// explicitly.
+func (c *Config) CurrentProfile() string {
+	if c.Current != "" {
+		return c.Current
+	}
 
+	return defaultProfile
+}
+
+// ProfileNames returns the names of all profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	var names []string
+
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (c *Config) migrate() {
+	if c.Account == nil {
+		return
+	}
+
+	if _, ok := c.Profiles[defaultProfile]; !ok {
+		prof := c.Profile(defaultProfile)
+		prof.Email = c.Account.Email
+		prof.Token = c.Account.Token
+	}
+
+	c.Account = nil
+}
+
+const defaultConfigDir = "~/.config/lab47"
+
+// configFile returns the path of a file within the configuration directory.
+func configFile(name string) (string, error) {
 	cfgDir := os.Getenv("LAB47_HOME")
 	if cfgDir == "" {
 		cfgDir = defaultConfigDir
 	}
 
-	path, err := homedir.Expand(filepath.Join(cfgDir, "svc.toml"))
+	return homedir.Expand(filepath.Join(cfgDir, name))
+}
+
+func secretKey(profile string) string {
+	return "token/" + profile
+}
+
+func (c *Config) secretStore() (SecretStore, error) {
+	if c.SecretStore == "" {
+		c.SecretStore = defaultSecretStore()
+	}
+
+	if c.store != nil && c.storeKind == c.SecretStore {
+		return c.store, nil
+	}
+
+	store, err := openSecretStore(c.SecretStore)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store = store
+	c.storeKind = c.SecretStore
+
+	return store, nil
+}
+
+// loadSecrets fills in the profile tokens from the secret store. It reports
+// whether any profile still had its token in plaintext.
+func (c *Config) loadSecrets() (bool, error) {
+	store, err := c.secretStore()
+	if err != nil || store == nil {
+		return false, err
+	}
+
+	var plaintext bool
+
+	c.stored = map[string]bool{}
+
+	for name, prof := range c.Profiles {
+		if prof.Token != "" {
+			plaintext = true
+			continue
+		}
+
+		token, err := store.Get(secretKey(name))
+		if err != nil {
+			if err == ErrSecretNotFound {
+				continue
+			}
+
+			return false, errors.Wrapf(err, "error loading token for profile %s", name)
+		}
+
+		prof.Token = token
+		c.stored[name] = true
+	}
+
+	return plaintext, nil
+}
+
+// saveSecrets writes the profile tokens to the secret store, removing those
+// of deleted or logged out profiles, and returns a copy of the configuration
+// safe to write to disk.
+func (c *Config) saveSecrets() (*Config, error) {
+	store, err := c.secretStore()
+	if err != nil || store == nil {
+		return c, err
+	}
+
+	out := *c
+	out.Profiles = map[string]*Profile{}
+
+	stored := map[string]bool{}
+
+	for name, prof := range c.Profiles {
+		if prof.Token != "" {
+			err = store.Set(secretKey(name), prof.Token)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error saving token for profile %s", name)
+			}
+
+			stored[name] = true
+		}
+
+		cp := *prof
+		cp.Token = ""
+		out.Profiles[name] = &cp
+	}
+
+	for name := range c.stored {
+		if stored[name] {
+			continue
+		}
+
+		err = store.Delete(secretKey(name))
+		if err != nil && err != ErrSecretNotFound {
+			return nil, errors.Wrapf(err, "error removing token for profile %s", name)
+		}
+	}
+
+	c.stored = stored
+
+	return &out, nil
+}
+
+func LoadConfig() (*Config, error) {
+	var cfg Config
+
+	path, err := configFile("svc.toml")
 	if err != nil {
 		return nil, err
 	}
@@ -47,31 +333,64 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	cfg.migrate()
+
+	plaintext, err := cfg.loadSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	if plaintext {
+		fmt.Fprintf(os.Stderr, "Moving plaintext tokens into the %s secret store...\n", cfg.SecretStore)
+
+		err = SaveConfig(&cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &cfg, nil
 }
 
-func SaveConfig(cfg *Config) error {
-	cfgDir := os.Getenv("LAB47_HOME")
-	if cfgDir == "" {
-		cfgDir = defaultConfigDir
+// loadProfile loads the configuration and returns it along with the profile
+// selected for the running command.
+func loadProfile(ctx context.Context) (*Config, *Profile, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error loading configuration")
 	}
 
-	path, err := homedir.Expand(filepath.Join(cfgDir, "svc.toml"))
+	return cfg, cfg.Profile(globalOptions(ctx).Profile), nil
+}
+
+// SaveConfig writes the configuration, readable only by the current user,
+// storing the tokens in the configured secret store.
+func SaveConfig(cfg *Config) error {
+	path, err := configFile("svc.toml")
 	if err != nil {
 		return err
 	}
 
-	err = os.MkdirAll(filepath.Dir(path), 0700)
+	out, err := cfg.saveSecrets()
 	if err != nil {
 		return err
 	}
 
-	w, err := os.Create(path)
+	if cfg.SecretStore == "" {
+		for _, prof := range out.Profiles {
+			if prof.Token != "" {
+				fmt.Fprintf(os.Stderr, "Warning: tokens are stored in plaintext in %s, use 'labctl config secret-store' to keep them in a secret store\n", path)
+				break
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err = toml.NewEncoder(&buf).Encode(out)
 	if err != nil {
 		return err
 	}
 
-	defer w.Close()
-
-	return toml.NewEncoder(w).Encode(cfg)
+	return writeFileAtomic(path, buf.Bytes())
 }
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+)
+
+// isNotFound reports whether a registry error means the manifest is missing.
+func isNotFound(err error) bool {
+	var te *transport.Error
+	return errors.As(err, &te) && te.StatusCode == http.StatusNotFound
+}
+
+// copier copies images between repositories, along with what cosign has
+// attached to them. Layers read from a repository on the same registry as
+// the destination are mounted by go-containerregistry rather than
+// downloaded and uploaded again.
+type copier struct {
+	ropts      []remote.Option
+	signatures bool
+
+	// status, if set, is told about each manifest as it's copied.
+	status func(format string, args ...interface{})
+}
+
+type copyResult struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Digest      string   `json:"digest"`
+	Platforms   int      `json:"platforms,omitempty"`
+	Attached    []string `json:"attached,omitempty"`
+	Skipped     bool     `json:"skipped,omitempty"`
+}
+
+func (r *copyResult) WriteText(w io.Writer) error {
+	if r.Skipped {
+		fmt.Fprintf(w, "%s is already at %s@%s\n", r.Source, r.Destination, r.Digest)
+	} else {
+		fmt.Fprintf(w, "Copied %s to %s@%s\n", r.Source, r.Destination, r.Digest)
+	}
+
+	if r.Platforms > 0 {
+		fmt.Fprintf(w, "  platforms: %d\n", r.Platforms)
+	}
+
+	for _, t := range r.Attached {
+		fmt.Fprintf(w, "  attached: %s\n", t)
+	}
+
+	return nil
+}
+
+func (c *copier) statusf(format string, args ...interface{}) {
+	if c.status != nil {
+		c.status(format, args...)
+	}
+}
+
+// write copies the manifest desc describes, and everything it references,
+// to dst.
+func (c *copier) write(desc *remote.Descriptor, dst name.Reference) (int, error) {
+	switch {
+	case desc.MediaType.IsIndex():
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return 0, err
+		}
+
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return 0, err
+		}
+
+		return len(im.Manifests), remote.WriteIndex(dst, idx, c.ropts...)
+	default:
+		img, err := desc.Image()
+		if err != nil {
+			return 0, err
+		}
+
+		return 0, remote.Write(dst, img, c.ropts...)
+	}
+}
+
+// copyAttached copies the signatures, attestations and SBOMs cosign
+// attached to digest that dst doesn't have yet, returning the tags copied.
+func (c *copier) copyAttached(src, dst name.Repository, digest gv1.Hash) ([]string, error) {
+	var copied []string
+
+	for _, suf := range cosignSuffixes {
+		tag := cosignTag(digest, suf)
+
+		sd, err := remote.Head(src.Tag(tag), c.ropts...)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+
+			return copied, errors.Wrapf(err, "error reading %s", tag)
+		}
+
+		if dd, err := remote.Head(dst.Tag(tag), c.ropts...); err == nil && dd.Digest == sd.Digest {
+			continue
+		}
+
+		desc, err := remote.Get(src.Tag(tag), c.ropts...)
+		if err != nil {
+			return copied, errors.Wrapf(err, "error reading %s", tag)
+		}
+
+		c.statusf("=> Copying %s\n", tag)
+
+		_, err = c.write(desc, dst.Tag(tag))
+		if err != nil {
+			return copied, errors.Wrapf(err, "error copying %s", tag)
+		}
+
+		copied = append(copied, tag)
+	}
+
+	return copied, nil
+}
+
+// signedDigests returns the digests cosign may have attached to for the
+// manifest desc describes: signatures can be attached to an index as well as
+// to each of the images within it.
+func signedDigests(desc *remote.Descriptor) ([]gv1.Hash, error) {
+	digests := []gv1.Hash{desc.Digest}
+
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range im.Manifests {
+			digests = append(digests, m.Digest)
+		}
+	}
+
+	return digests, nil
+}
+
+// present returns the digest of src and whether dst already has it.
+func (c *copier) present(src, dst name.Reference) (gv1.Hash, bool, error) {
+	sd, err := remote.Head(src, c.ropts...)
+	if err != nil {
+		return gv1.Hash{}, false, errors.Wrapf(err, "error reading %s", src)
+	}
+
+	// Any problem reading dst, such as it not existing yet, is left for
+	// the write to report.
+	dd, err := remote.Head(dst, c.ropts...)
+	if err != nil {
+		return sd.Digest, false, nil
+	}
+
+	return sd.Digest, dd.Digest == sd.Digest, nil
+}
+
+// copy copies src to dst. The manifest isn't copied again if dst already has
+// the same digest, unless force is set, but signatures attached since the
+// last copy still are.
+func (c *copier) copy(src, dst name.Reference, force bool) (*copyResult, error) {
+	result := &copyResult{
+		Source:      src.String(),
+		Destination: dst.String(),
+	}
+
+	// Compare with HEAD requests first, which registries such as Docker Hub
+	// don't count against their pull limits.
+	if !force {
+		digest, present, err := c.present(src, dst)
+		if err != nil {
+			return nil, err
+		}
+
+		if present {
+			result.Digest = digest.String()
+			result.Skipped = true
+		}
+	}
+
+	var desc *remote.Descriptor
+
+	if !result.Skipped {
+		var err error
+
+		desc, err = remote.Get(src, c.ropts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading %s", src)
+		}
+
+		result.Digest = desc.Digest.String()
+
+		c.statusf("Copying %s to %s...\n", src, dst)
+
+		result.Platforms, err = c.write(desc, dst)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error copying %s to %s", src, dst)
+		}
+	}
+
+	if !c.signatures {
+		return result, nil
+	}
+
+	// dst has the same manifest as src, so it's read from there rather than
+	// pulling it from upstream again.
+	if desc == nil {
+		var err error
+
+		desc, err = remote.Get(dst, c.ropts...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading %s", dst)
+		}
+	}
+
+	digests, err := signedDigests(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range digests {
+		tags, err := c.copyAttached(src.Context(), dst.Context(), d)
+		result.Attached = append(result.Attached, tags...)
+
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// destinationRef parses the destination of a copy. A destination without a
+// tag or digest takes the source's tag, or its digest if it has none.
+func destinationRef(prof *Profile, s string, src name.Reference) (name.Reference, error) {
+	qualified := qualifyName(prof, s)
+
+	last := qualified[strings.LastIndexByte(qualified, '/')+1:]
+
+	if !strings.ContainsAny(last, ":@") {
+		switch r := src.(type) {
+		case name.Tag:
+			qualified += ":" + r.TagStr()
+		case name.Digest:
+			qualified += "@" + r.DigestStr()
+		}
+	}
+
+	ref, err := name.ParseReference(qualified)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference %s", s)
+	}
+
+	return ref, nil
+}
+
+func (c *CLI) copyF(ctx context.Context, opts struct {
+	NoSignatures bool `long:"no-signatures" description:"don't copy the signatures and attestations attached by cosign"`
+	Force        bool `short:"f" long:"force" description:"copy even if the destination already has the same digest"`
+	Jobs         int  `short:"j" long:"jobs" default:"4" description:"how many layers to copy at once"`
+
+	Pos struct {
+		Src string `positional-arg-name:"src"`
+		Dst string `positional-arg-name:"dst"`
+	} `positional-args:"yes"`
+}) (*copyResult, error) {
+	if opts.Pos.Src == "" || opts.Pos.Dst == "" {
+		return nil, fmt.Errorf("requires the source and destination references as arguments")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := name.ParseReference(qualifyName(prof, opts.Pos.Src))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference %s", opts.Pos.Src)
+	}
+
+	dst, err := destinationRef(prof, opts.Pos.Dst, src)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &copier{
+		ropts:      registryOptions(ctx, prof, opts.Jobs),
+		signatures: !opts.NoSignatures,
+		status: func(format string, args ...interface{}) {
+			statusf(ctx, format, args...)
+		},
+	}
+
+	if src.Context().RegistryStr() == dst.Context().RegistryStr() {
+		statusf(ctx, "Both sides are on %s, mounting layers instead of copying them\n", dst.Context().RegistryStr())
+	}
+
+	return cp.copy(src, dst, opts.Force)
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/lab47/labctl/pkg/client"
+)
+
+const tokenUser = client.TokenUser
+
+// credentialHelper implements the docker credential helper protocol on top
+// of the labctl profiles, matching servers against each profile's registry
+// host. The selected profile is preferred when several use the same host.
+type credentialHelper struct {
+	cfg     *Config
+	profile string
+}
+
+// registryHost reduces a server URL as passed by docker to its host.
+func registryHost(server string) string {
+	if strings.Contains(server, "://") {
+		if u, err := url.Parse(server); err == nil {
+			return u.Host
+		}
+	}
+
+	if idx := strings.IndexByte(server, '/'); idx != -1 {
+		server = server[:idx]
+	}
+
+	return server
+}
+
+// find returns the profile holding credentials for server, if any.
+func (h *credentialHelper) find(server string) (*Profile, bool) {
+	host := registryHost(server)
+
+	if prof, ok := h.cfg.Lookup(h.profile); ok && prof.Token != "" && prof.RegistryHost() == host {
+		return prof, true
+	}
+
+	for _, name := range h.cfg.ProfileNames() {
+		prof, _ := h.cfg.Lookup(name)
+
+		if prof.Token != "" && prof.RegistryHost() == host {
+			return prof, true
+		}
+	}
+
+	return nil, false
+}
+
+func (h *credentialHelper) Add(creds *credentials.Credentials) error {
+	if creds.Username != tokenUser {
+		return fmt.Errorf("only %s credentials can be stored by labctl", tokenUser)
+	}
+
+	prof, ok := h.find(creds.ServerURL)
+	if !ok {
+		prof = h.cfg.Profile(h.profile)
+
+		if prof.RegistryHost() != registryHost(creds.ServerURL) {
+			return fmt.Errorf("profile %s is not configured for %s", prof.Name, creds.ServerURL)
+		}
+	}
+
+	prof.Token = creds.Secret
+
+	return SaveConfig(h.cfg)
+}
+
+func (h *credentialHelper) Delete(server string) error {
+	prof, ok := h.find(server)
+	if !ok {
+		return credentials.NewErrCredentialsNotFound()
+	}
+
+	prof.Token = ""
+
+	return SaveConfig(h.cfg)
+}
+
+func (h *credentialHelper) Get(server string) (string, string, error) {
+	prof, ok := h.find(server)
+	if !ok {
+		return "", "", credentials.NewErrCredentialsNotFound()
+	}
+
+	return tokenUser, prof.Token, nil
+}
+
+func (h *credentialHelper) List() (map[string]string, error) {
+	servers := map[string]string{}
+
+	for _, name := range h.cfg.ProfileNames() {
+		prof, _ := h.cfg.Lookup(name)
+
+		if prof.Token != "" {
+			servers[prof.RegistryHost()] = tokenUser
+		}
+	}
+
+	return servers, nil
+}
+
+func (c *CLI) credentialHelperF(ctx context.Context, opts struct {
+	Pos struct {
+		Action string `positional-arg-name:"get|store|erase|list"`
+	} `positional-args:"yes"`
+}) error {
+	if opts.Pos.Action == "" {
+		return fmt.Errorf("requires action (get, store, erase, list) as argument")
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	h := &credentialHelper{
+		cfg:     cfg,
+		profile: globalOptions(ctx).Profile,
+	}
+
+	err = credentials.HandleCommand(h, opts.Pos.Action, os.Stdin, os.Stdout)
+	if err != nil {
+		// The protocol expects the bare error message on stdout.
+		fmt.Fprintln(os.Stdout, err)
+		return exitCode(1)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+// authFile is a docker style config.json. It's kept as generic JSON so that
+// settings labctl doesn't know about survive being rewritten.
+type authFile map[string]interface{}
+
+// dockerConfigPath returns the location of the docker CLI configuration,
+// honoring DOCKER_CONFIG.
+func dockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+
+	return homedir.Expand("~/.docker/config.json")
+}
+
+func loadAuthFile(path string) (authFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return authFile{}, nil
+		}
+
+		return nil, err
+	}
+
+	var af authFile
+
+	err = json.Unmarshal(data, &af)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", path)
+	}
+
+	if af == nil {
+		af = authFile{}
+	}
+
+	return af, nil
+}
+
+func (a authFile) save(path string) error {
+	data, err := json.MarshalIndent(a, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// section returns the named object within the file, creating it if needed.
+func (a authFile) section(name string) map[string]interface{} {
+	if sec, ok := a[name].(map[string]interface{}); ok {
+		return sec
+	}
+
+	sec := map[string]interface{}{}
+	a[name] = sec
+
+	return sec
+}
+
+// setCredHelper configures the credential helper used for server.
+func (a authFile) setCredHelper(server, helper string) {
+	a.section("credHelpers")[server] = helper
+}
+
+// setAuth stores basic auth credentials for server, keeping any other
+// fields of an existing entry.
+func (a authFile) setAuth(server, user, pass string) {
+	auths := a.section("auths")
+
+	entry, ok := auths[server].(map[string]interface{})
+	if !ok {
+		entry = map[string]interface{}{}
+		auths[server] = entry
+	}
+
+	entry["auth"] = base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/pkg/errors"
+)
+
+// Exit codes returned by labctl. They're part of the CLI's interface, so
+// existing values must not change.
+const (
+	ExitOK                 = 0
+	ExitError              = 1
+	ExitUsage              = 2
+	ExitUnauthenticated    = 3
+	ExitForbidden          = 4
+	ExitNotFound           = 5
+	ExitConflict           = 6
+	ExitInsufficientCredit = 7
+	ExitNetwork            = 8
+	ExitValidation         = 9
+	ExitServer             = 10
+)
+
+var kindExitCodes = map[client.Kind]int{
+	client.KindUnauthenticated:    ExitUnauthenticated,
+	client.KindForbidden:          ExitForbidden,
+	client.KindNotFound:           ExitNotFound,
+	client.KindConflict:           ExitConflict,
+	client.KindInsufficientCredit: ExitInsufficientCredit,
+	client.KindNetwork:            ExitNetwork,
+	client.KindValidation:         ExitValidation,
+	client.KindServer:             ExitServer,
+}
+
+// errNotLoggedIn is returned by commands that need a token when the profile
+// doesn't have one.
+var errNotLoggedIn = &client.Error{
+	Kind: client.KindUnauthenticated,
+	Err:  errors.New("Please login first"),
+}
+
+// errorKind classifies errors from the API as well as from registries.
+func errorKind(err error) client.Kind {
+	if kind := client.KindOf(err); kind != client.KindUnknown {
+		return kind
+	}
+
+	var te *transport.Error
+	if errors.As(err, &te) {
+		return client.KindForStatus(te.StatusCode)
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return client.KindNetwork
+	}
+
+	return client.KindUnknown
+}
+
+// exitCodeFor returns the exit code the process should use for err.
+func exitCodeFor(err error) int {
+	if code, ok := kindExitCodes[errorKind(err)]; ok {
+		return code
+	}
+
+	return ExitError
+}
+
+// permissionError explains a forbidden response in terms of the roles
+// needed to perform action in namespace. Other errors are returned as is.
+func permissionError(err error, action, namespace string, roles ...string) error {
+	var ce *client.Error
+	if !errors.As(err, &ce) || ce.Kind != client.KindForbidden {
+		return err
+	}
+
+	msg := fmt.Sprintf("permission denied: %s in namespace %s requires the %s role",
+		action, namespace, strings.Join(roles, " or "))
+
+	if ce.Remote != nil && ce.Remote.ErrorS != "" {
+		msg += fmt.Sprintf(" (%s)", ce.Remote.ErrorS)
+	}
+
+	return &client.Error{
+		Kind:   client.KindForbidden,
+		Status: ce.Status,
+		Err:    errors.New(msg),
+	}
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// exportFormats are the formats credentials can be exported in.
+var exportFormats = map[string]func(*exportCreds) ([]byte, error){
+	"github":       exportDotenv,
+	"gitlab":       exportGitlab,
+	"netrc":        exportNetrc,
+	"dockerconfig": exportDockerConfig,
+	"kubernetes":   exportKubernetes,
+}
+
+func exportFormatNames() []string {
+	var names []string
+
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// exportOptions are the flags of the commands that can export the
+// credentials they issue.
+type exportOptions struct {
+	Format  string `long:"format" description:"emit the credentials for use elsewhere: github, gitlab, netrc, dockerconfig or kubernetes"`
+	WriteTo string `long:"write-to" description:"write the exported credentials to this file (mode 0600) instead of printing them"`
+}
+
+func (o *exportOptions) validate() error {
+	if o.Format == "" {
+		if o.WriteTo != "" {
+			return fmt.Errorf("--write-to requires --format")
+		}
+
+		return nil
+	}
+
+	if _, ok := exportFormats[o.Format]; !ok {
+		return fmt.Errorf("unknown format '%s', must be one of: %s", o.Format, strings.Join(exportFormatNames(), ", "))
+	}
+
+	if o.WriteTo != "" {
+		err := checkWritable(o.WriteTo)
+		if err != nil {
+			return errors.Wrapf(err, "unable to write to %s", o.WriteTo)
+		}
+	}
+
+	return nil
+}
+
+// checkWritable makes sure writeFileAtomic can write path, so that
+// credentials aren't issued only to find they can't be saved.
+func checkWritable(path string) error {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	dir := filepath.Dir(path)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// exportFailed reports that exporting freshly issued credentials failed.
+// The token can't be fetched again, so the error carries it.
+func exportFailed(err error, token string) error {
+	return errors.Errorf("unable to export the credentials: %v; the token can't be shown again, save it now: %s", err, token)
+}
+
+// exportCreds are the credentials of a machine account to be exported.
+type exportCreds struct {
+	Name     string
+	Registry string
+	Token    string
+}
+
+// credentialExport is the result of exporting credentials. Without a path the
+// content is printed as is so that it can be piped where it's needed.
+type credentialExport struct {
+	Format  string `json:"format"`
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+func (e *credentialExport) WriteText(w io.Writer) error {
+	if e.Path != "" {
+		_, err := fmt.Fprintf(w, "Wrote %s credentials to %s\n", e.Format, e.Path)
+		return err
+	}
+
+	_, err := io.WriteString(w, e.Content)
+	return err
+}
+
+// export renders creds in the selected format, writing them to a file if
+// requested. It returns nil when no format was selected.
+func (o *exportOptions) export(creds *exportCreds) (*credentialExport, error) {
+	if o.Format == "" {
+		return nil, nil
+	}
+
+	data, err := exportFormats[o.Format](creds)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &credentialExport{Format: o.Format}
+
+	if o.WriteTo == "" {
+		result.Content = string(data)
+		return result, nil
+	}
+
+	err = writeFileAtomic(o.WriteTo, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error writing %s", o.WriteTo)
+	}
+
+	result.Path = o.WriteTo
+
+	return result, nil
+}
+
+// dockerConfigJSON returns a docker config.json holding credentials for host.
+func dockerConfigJSON(host, user, pass string) []byte {
+	cfg := authFile{}
+	cfg.setAuth("https://"+host, user, pass)
+
+	data, _ := json.MarshalIndent(cfg, "", "    ")
+
+	return append(data, '\n')
+}
+
+// newK8sSecret returns an image pull secret holding credentials for host.
+func newK8sSecret(name, host, user, pass string) *k8sSecret {
+	secret := &k8sSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Data: map[string]string{
+			".dockerconfigjson": base64.StdEncoding.EncodeToString(dockerConfigJSON(host, user, pass)),
+		},
+		Type: "kubernetes.io/dockerconfigjson",
+	}
+
+	secret.Metadata.Name = name
+
+	return secret
+}
+
+// exportDotenv emits KEY=value lines, which `gh secret set -f` imports as
+// GitHub Actions secrets.
+func exportDotenv(creds *exportCreds) ([]byte, error) {
+	return []byte(fmt.Sprintf("VCR_REGISTRY=%s\nVCR_USERNAME=%s\nVCR_TOKEN=%s\n",
+		creds.Registry, tokenUser, creds.Token)), nil
+}
+
+type gitlabVariable struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Masked    bool   `json:"masked"`
+	Protected bool   `json:"protected"`
+}
+
+// exportGitlab emits GitLab CI variables as accepted by its variables API.
+func exportGitlab(creds *exportCreds) ([]byte, error) {
+	vars := []gitlabVariable{
+		{Key: "VCR_REGISTRY", Value: creds.Registry},
+		{Key: "VCR_USERNAME", Value: tokenUser},
+		{Key: "VCR_TOKEN", Value: creds.Token, Masked: true},
+	}
+
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+func exportNetrc(creds *exportCreds) ([]byte, error) {
+	return []byte(fmt.Sprintf("machine %s\n  login %s\n  password %s\n",
+		creds.Registry, tokenUser, creds.Token)), nil
+}
+
+func exportDockerConfig(creds *exportCreds) ([]byte, error) {
+	return dockerConfigJSON(creds.Registry, tokenUser, creds.Token), nil
+}
+
+var invalidK8sName = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func exportKubernetes(creds *exportCreds) ([]byte, error) {
+	name := strings.Trim(invalidK8sName.ReplaceAllString(strings.ToLower(creds.Name), "-"), "-")
+	if name == "" {
+		name = "vcr-pub"
+	}
+
+	return yaml.Marshal(newK8sSecret(name, creds.Registry, tokenUser, creds.Token))
+}
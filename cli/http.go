@@ -1,141 +1,75 @@
 package cli
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"io"
+	"net"
 	"net/http"
-	"net/url"
-	"strings"
+	"sync"
+	"time"
 
-	"github.com/pkg/errors"
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/lab47/labctl/pkg/retry"
 )
 
-const defaultBase = "https://svc.lab47.dev"
-
-var baseURL = defaultBase
-
-func currentServer() string {
-	if baseURL == defaultBase {
-		return "vcr.pub"
-	}
-
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		panic(err)
-	}
-
-	return u.Host
+// baseTransport is used for all requests to the lab47 API and registries.
+// Overall request deadlines come from the request context, the transport
+// only bounds the individual connection phases.
+var baseTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          10,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
 }
 
-type RemoteError struct {
-	Code   int    `json:"code"`
-	ErrorS string `json:"error"`
-}
+// apiClient returns a client for the lab47 API authenticated as prof, with
+// the request deadline of the running command.
+func apiClient(ctx context.Context, prof *Profile) *client.Client {
+	cl := client.New(prof.API(), prof.Token, prof.httpClient())
+	cl.Timeout = globalOptions(ctx).Timeout
 
-func (r *RemoteError) Error() string {
-	return fmt.Sprintf("remote error: %s (%d)", r.ErrorS, r.Code)
+	return cl
 }
 
-type Status struct {
-	Status string `json:"status"`
-}
-
-func perform(ctx context.Context, method, path string, hdrs http.Header, val interface{}, ret interface{}) error {
-	var r io.Reader
-
-	if val != nil {
-		body, err := json.Marshal(val)
-		if err != nil {
-			return errors.Wrapf(err, "error marshaling request")
-		}
-
-		r = bytes.NewReader(body)
-	}
-
-	var url string
-
-	if strings.HasPrefix(path, "https://") {
-		url = path
-	} else {
-		url = baseURL + path
-	}
-
-	req, err := http.NewRequest(method, url, r)
-	if err != nil {
-		return err
-	}
-
-	for k, v := range hdrs {
-		req.Header[k] = v
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return errors.Wrapf(err, "error posting to: %s", path)
+// withTimeout bounds ctx by the running command's timeout, for commands that
+// only read a few small documents from a registry.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := globalOptions(ctx).Timeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode > 299 {
-		if resp.Header.Get("Content-Type") == "application/json" {
-			var er RemoteError
-
-			err = json.NewDecoder(resp.Body).Decode(&er)
-			if err != nil {
-				return errors.Wrapf(err, "error decoding response")
-			}
+	return context.WithCancel(ctx)
+}
 
-			return &er
-		}
+var (
+	registryTransportsMu sync.Mutex
+	registryTransports   = map[time.Duration]*http.Transport{}
+)
 
-		return fmt.Errorf("Unexpected status: %d", resp.StatusCode)
+// registryTransport returns the transport for registry requests made by the
+// running command. The command's timeout bounds how long a registry has to
+// start answering each request rather than the whole request, so pushing
+// and pulling large blobs isn't cut short while a stalled registry is.
+func registryTransport(ctx context.Context, prof *Profile) http.RoundTripper {
+	timeout := globalOptions(ctx).Timeout
+	if timeout <= 0 {
+		return prof.transport()
 	}
 
-	if ret == nil {
-		return nil
-	}
+	registryTransportsMu.Lock()
+	defer registryTransportsMu.Unlock()
 
-	err = json.NewDecoder(resp.Body).Decode(ret)
-	if err != nil {
-		return errors.Wrapf(err, "error decoding response")
+	tr, ok := registryTransports[timeout]
+	if !ok {
+		tr = baseTransport.Clone()
+		tr.ResponseHeaderTimeout = timeout
+		registryTransports[timeout] = tr
 	}
 
-	return nil
-}
-
-func Post(ctx context.Context, path string, req, ret interface{}) error {
-	return perform(ctx, "POST", path, nil, req, ret)
-}
-
-func setAuthorization(hdrs http.Header, user, pass string) {
-	hdrs.Set("Authorization",
-		"Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
-}
-
-func TokenPost(ctx context.Context, token, path string, req, ret interface{}) error {
-	hdrs := http.Header{}
-	setAuthorization(hdrs, "cytoken", token)
-	return perform(ctx, "POST", path, hdrs, req, ret)
-}
-
-func TokenPut(ctx context.Context, token, path string, req, ret interface{}) error {
-	hdrs := http.Header{}
-	setAuthorization(hdrs, "cytoken", token)
-	return perform(ctx, "PUT", path, hdrs, req, ret)
-}
-
-func Get(ctx context.Context, path string, ret interface{}) error {
-	return perform(ctx, "POST", path, nil, nil, ret)
-}
-
-func TokenGet(ctx context.Context, token, path string, ret interface{}) error {
-	hdrs := http.Header{}
-	setAuthorization(hdrs, "cytoken", token)
-	return perform(ctx, "GET", path, hdrs, nil, ret)
+	return retry.NewTransport(tr, prof.retries())
 }
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lab47/labctl/types"
+)
+
+// semverPattern matches release tags such as v1.2.0 or 1.2.0.
+const semverPattern = `^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)$`
+
+// immutableOptions are the flags controlling which tags can't be moved or
+// deleted once pushed.
+type immutableOptions struct {
+	All      bool     `long:"immutable-tags" description:"make every tag immutable"`
+	Patterns []string `long:"immutable-pattern" description:"make tags matching this regular expression immutable, or 'semver' for release tags, may be repeated"`
+	Mutable  bool     `long:"mutable-tags" description:"remove all immutability rules"`
+}
+
+// rules returns the immutability rules described by the flags, or nil if
+// none were set.
+func (o *immutableOptions) rules() (*types.ImmutableTags, error) {
+	if o.Mutable {
+		if o.All || len(o.Patterns) > 0 {
+			return nil, fmt.Errorf("--mutable-tags can't be combined with other immutability options")
+		}
+
+		return &types.ImmutableTags{}, nil
+	}
+
+	if !o.All && len(o.Patterns) == 0 {
+		return nil, nil
+	}
+
+	rules := &types.ImmutableTags{All: o.All}
+
+	for _, pat := range o.Patterns {
+		if pat == "semver" {
+			pat = semverPattern
+		}
+
+		if _, err := regexp.Compile(pat); err != nil {
+			return nil, fmt.Errorf("invalid --immutable-pattern %q: %s", pat, err)
+		}
+
+		rules.Patterns = append(rules.Patterns, pat)
+	}
+
+	return rules, nil
+}
+
+// describeImmutable summarizes immutability rules in a line.
+func describeImmutable(rules *types.ImmutableTags) string {
+	switch {
+	case rules == nil || (!rules.All && len(rules.Patterns) == 0):
+		return "none"
+	case rules.All:
+		return "all tags"
+	default:
+		var parts []string
+
+		for _, pat := range rules.Patterns {
+			parts = append(parts, "/"+pat+"/")
+		}
+
+		return "tags matching " + strings.Join(parts, ", ")
+	}
+}
+
+// tagLocked reports whether the rules make tag immutable, along with the
+// rule responsible.
+func tagLocked(rules *types.ImmutableTags, tag string) (bool, string) {
+	if rules == nil {
+		return false, ""
+	}
+
+	if rules.All {
+		return true, "all tags are immutable"
+	}
+
+	for _, pat := range rules.Patterns {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			continue
+		}
+
+		if re.MatchString(tag) {
+			return true, fmt.Sprintf("matches /%s/", pat)
+		}
+	}
+
+	return false, ""
+}
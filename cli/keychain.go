@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// profileKeychain authenticates to the profile's registry with its token,
+// deferring to the docker credentials for every other registry so that
+// images can be moved between vcr and elsewhere.
+type profileKeychain struct {
+	prof *Profile
+}
+
+func (k *profileKeychain) Resolve(res authn.Resource) (authn.Authenticator, error) {
+	if res.RegistryStr() == k.prof.RegistryHost() && k.prof.Token != "" {
+		return profileAuth(k.prof), nil
+	}
+
+	return authn.DefaultKeychain.Resolve(res)
+}
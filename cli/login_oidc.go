@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// readOIDCToken reads an ID token from a file or environment variable, as
+// provided by CI systems and Kubernetes projected service account tokens.
+func readOIDCToken(file, env string) (string, error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrapf(err, "error reading OIDC token")
+		}
+
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	tok := strings.TrimSpace(os.Getenv(env))
+	if tok == "" {
+		return "", fmt.Errorf("environment variable %s is empty", env)
+	}
+
+	return tok, nil
+}
+
+// defaultOIDCIssuers are the issuers OIDC tokens are accepted from when
+// none are given with --oidc-issuer.
+var defaultOIDCIssuers = []string{
+	"https://token.actions.githubusercontent.com",
+	"https://gitlab.com",
+}
+
+// allowedIssuer reports whether iss is one of issuers, ignoring trailing
+// slashes.
+func allowedIssuer(iss string, issuers []string) bool {
+	for _, allowed := range issuers {
+		if strings.TrimRight(allowed, "/") == strings.TrimRight(iss, "/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyOIDCToken checks an ID token against the keys of its issuer, which
+// must be one of issuers. The issuer named in the token is checked before
+// its discovery document is fetched, so an untrusted token can't make us
+// contact an arbitrary server. The server performs the same checks against
+// the namespace's trust policy; doing them here first turns an expired or
+// mangled token into a clear error rather than a rejected exchange.
+func verifyOIDCToken(ctx context.Context, prof *Profile, raw, audience string, issuers []string) (*oidc.IDToken, error) {
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing OIDC token")
+	}
+
+	var claims jwt.Claims
+
+	err = tok.UnsafeClaimsWithoutVerification(&claims)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing OIDC token")
+	}
+
+	if claims.Issuer == "" {
+		return nil, fmt.Errorf("OIDC token does not name its issuer")
+	}
+
+	if !allowedIssuer(claims.Issuer, issuers) {
+		return nil, &client.Error{
+			Kind: client.KindUnauthenticated,
+			Err:  fmt.Errorf("OIDC token is from %s, which is not an allowed issuer (see --oidc-issuer)", claims.Issuer),
+		}
+	}
+
+	if timeout := globalOptions(ctx).Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx = oidc.ClientContext(ctx, prof.httpClient())
+
+	prov, err := oidc.NewProvider(ctx, claims.Issuer)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error contacting OIDC issuer %s", claims.Issuer)
+	}
+
+	ver := prov.Verifier(&oidc.Config{
+		ClientID:          audience,
+		SkipClientIDCheck: audience == "",
+	})
+
+	idt, err := ver.Verify(ctx, raw)
+	if err != nil {
+		return nil, &client.Error{
+			Kind: client.KindUnauthenticated,
+			Err:  errors.Wrapf(err, "OIDC token rejected"),
+		}
+	}
+
+	return idt, nil
+}
+
+// oidcLoginOptions are the login flags used to log in with an OIDC token.
+type oidcLoginOptions struct {
+	Namespace string
+	File      string
+	Env       string
+	Audience  string
+	Issuers   []string
+}
+
+// oidcLogin exchanges an OIDC ID token for a short lived token and stores it.
+func (c *CLI) oidcLogin(ctx context.Context, opts oidcLoginOptions) (*loginResult, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("namespace (-n) whose trust policy allows the token is required")
+	}
+
+	raw, err := readOIDCToken(opts.File, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := opts.Issuers
+	if len(issuers) == 0 {
+		issuers = defaultOIDCIssuers
+	}
+
+	idt, err := verifyOIDCToken(ctx, prof, raw, opts.Audience, issuers)
+	if err != nil {
+		return nil, err
+	}
+
+	statusf(ctx, "Exchanging OIDC token for %s from %s...\n", idt.Subject, idt.Issuer)
+
+	tv, err := apiClient(ctx, prof).ExchangeToken(ctx, opts.Namespace, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	prof.Email = ""
+	prof.Token = tv.Token
+
+	err = SaveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := tv.Subject
+	if subject == "" {
+		subject = idt.Subject
+	}
+
+	return &loginResult{
+		Profile:   prof.Name,
+		API:       prof.API(),
+		Subject:   subject,
+		ExpiresAt: tv.ExpiresAt,
+	}, nil
+}
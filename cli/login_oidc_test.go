@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/lab47/labctl/pkg/oidcissuer"
+)
+
+// serveIssuer serves a stand-in issuer, counting the requests made to it.
+func serveIssuer(t *testing.T) (*oidcissuer.Issuer, *int32) {
+	t.Helper()
+
+	iss, err := oidcissuer.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hits int32
+
+	h := iss.Handler()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	iss.URL = srv.URL
+
+	return iss, &hits
+}
+
+func TestVerifyOIDCToken(t *testing.T) {
+	iss, _ := serveIssuer(t)
+	prof := &Profile{}
+
+	raw, err := iss.Token(oidcissuer.Claims{Subject: "ci", Audience: []string{"vcr.pub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idt, err := verifyOIDCToken(context.Background(), prof, raw, "vcr.pub", []string{iss.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idt.Subject != "ci" {
+		t.Errorf("subject is %q", idt.Subject)
+	}
+
+	_, err = verifyOIDCToken(context.Background(), prof, raw, "other", []string{iss.URL})
+	if !client.IsKind(err, client.KindUnauthenticated) {
+		t.Errorf("token for another audience: %v", err)
+	}
+
+	expired, err := iss.Token(oidcissuer.Claims{Subject: "ci", TTL: -time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifyOIDCToken(context.Background(), prof, expired, "", []string{iss.URL})
+	if !client.IsKind(err, client.KindUnauthenticated) {
+		t.Errorf("expired token: %v", err)
+	}
+}
+
+func TestVerifyOIDCTokenIssuerNotAllowed(t *testing.T) {
+	iss, hits := serveIssuer(t)
+
+	raw, err := iss.Token(oidcissuer.Claims{Subject: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifyOIDCToken(context.Background(), &Profile{}, raw, "", defaultOIDCIssuers)
+	if !client.IsKind(err, client.KindUnauthenticated) {
+		t.Errorf("token from an issuer that isn't allowed: %v", err)
+	}
+
+	if n := atomic.LoadInt32(hits); n != 0 {
+		t.Errorf("issuer that isn't allowed was contacted %d times", n)
+	}
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/lab47/labctl/types"
+	"github.com/pkg/browser"
+	"github.com/pkg/errors"
+)
+
+// webLoginTimeout is how long to wait for the browser to be redirected back
+// after approving a login.
+const webLoginTimeout = 5 * time.Minute
+
+// randomString returns n random bytes encoded for use in a URL.
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// pkceChallenge derives the S256 code challenge for verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// webLogin logs in by sending the user to the browser, which redirects back
+// to a listener on the loopback interface with a code. The code is bound to
+// this process with PKCE so that it's useless to anyone else observing it.
+func webLogin(ctx context.Context, prof *Profile) (*types.OAuthTokenResponse, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listening for login callback")
+	}
+
+	defer l.Close()
+
+	redirect := fmt.Sprintf("http://127.0.0.1:%d/callback", l.Addr().(*net.TCPAddr).Port)
+
+	api := apiClient(ctx, prof)
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", client.OAuthClientID)
+	q.Set("redirect_uri", redirect)
+	q.Set("state", state)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	authURL := api.AuthorizeURL() + "?" + q.Encode()
+
+	statusf(ctx, "Opening browser to log in...\n")
+
+	err = browser.OpenURL(authURL)
+	if err != nil {
+		statusf(ctx, "Error opening browser. Please go to:\n%s\n", authURL)
+	}
+
+	statusf(ctx, "Waiting for login to complete...\n")
+
+	// Callbacks without our state aren't from the login we started, so
+	// they're ignored while waiting for the one that is.
+	vals, err := waitForCallback(ctx, l, "/callback", webLoginTimeout, func(vals url.Values) bool {
+		return vals.Get("state") == state
+	})
+	if err == context.Canceled {
+		return nil, fmt.Errorf("login canceled")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("timed out waiting for login")
+	}
+
+	if e := vals.Get("error"); e != "" {
+		return nil, &client.Error{
+			Kind: client.KindUnauthenticated,
+			Err:  fmt.Errorf("login failed: %s", e),
+		}
+	}
+
+	code := vals.Get("code")
+	if code == "" {
+		return nil, fmt.Errorf("login callback did not include a code")
+	}
+
+	return api.ExchangeAuthCode(ctx, code, verifier, redirect)
+}
+
+// deviceLogin logs in by showing a code that is approved in a browser on
+// any device, polling until that happens. It's meant for sessions where no
+// browser can be opened, such as over SSH.
+func deviceLogin(ctx context.Context, prof *Profile) (*types.OAuthTokenResponse, error) {
+	api := apiClient(ctx, prof)
+
+	dc, err := api.StartDeviceLogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if dc.VerificationURIComplete != "" {
+		statusf(ctx, "To log in, visit:\n\n  %s\n\nand confirm the code %s\n", dc.VerificationURIComplete, dc.UserCode)
+	} else {
+		statusf(ctx, "To log in, visit:\n\n  %s\n\nand enter the code %s\n", dc.VerificationURI, dc.UserCode)
+	}
+
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	expires := time.Duration(dc.ExpiresIn) * time.Second
+	if expires <= 0 {
+		expires = 10 * time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, expires)
+	defer cancel()
+
+	statusf(ctx, "Waiting for approval...\n")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for the login to be approved")
+		case <-time.After(interval):
+		}
+
+		tv, err := api.PollDeviceLogin(ctx, dc.DeviceCode)
+		switch {
+		case err == nil:
+			return tv, nil
+		case err == client.ErrAuthorizationPending:
+			// keep waiting
+		case err == client.ErrSlowDown:
+			interval += 5 * time.Second
+		default:
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("timed out waiting for the login to be approved")
+			}
+
+			return nil, err
+		}
+	}
+}
+
+// oauthLogin runs a browser or device code login and stores the token.
+func (c *CLI) oauthLogin(ctx context.Context, device bool) (*loginResult, error) {
+	cfg, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tv *types.OAuthTokenResponse
+
+	if device {
+		tv, err = deviceLogin(ctx, prof)
+	} else {
+		tv, err = webLogin(ctx, prof)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tv.Email != "" {
+		prof.Email = tv.Email
+	}
+
+	prof.Token = tv.Token
+
+	err = SaveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &loginResult{
+		Profile: prof.Name,
+		Email:   prof.Email,
+		API:     prof.API(),
+	}, nil
+}
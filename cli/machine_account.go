@@ -0,0 +1,446 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lab47/labctl/types"
+)
+
+// scopeActions are the actions a machine account can be granted on a
+// repository.
+var scopeActions = map[string]bool{
+	"pull":   true,
+	"push":   true,
+	"delete": true,
+	"admin":  true,
+}
+
+// parseScope parses a scope given as repository:action[,action...], where
+// a repository of * applies to every repository in the namespace.
+func parseScope(s string) (types.MachineAccountScope, error) {
+	var scope types.MachineAccountScope
+
+	idx := strings.LastIndexByte(s, ':')
+	if idx <= 0 || idx == len(s)-1 {
+		return scope, fmt.Errorf("invalid scope %q, expected repository:action[,action...]", s)
+	}
+
+	scope.Repository = s[:idx]
+
+	for _, action := range strings.Split(s[idx+1:], ",") {
+		action = strings.TrimSpace(action)
+
+		if !scopeActions[action] {
+			return scope, fmt.Errorf("invalid action %q in scope %q, expected pull, push, delete or admin", action, s)
+		}
+
+		scope.Actions = append(scope.Actions, action)
+	}
+
+	return scope, nil
+}
+
+// parseAllowedIP normalizes an address or CIDR range to CIDR form.
+func parseAllowedIP(s string) (string, error) {
+	if _, ipnet, err := net.ParseCIDR(s); err == nil {
+		return ipnet.String(), nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address or CIDR range: %s", s)
+	}
+
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+
+	return ip.String() + "/128", nil
+}
+
+// writeRestrictions writes the limits placed on a machine account, lined up
+// with the other fields of the machine account block.
+func writeRestrictions(w io.Writer, r *types.MachineAccountRestrictions, expiresAt *time.Time) {
+	expires := "never"
+	if expiresAt != nil {
+		expires = expiresAt.Format(time.RFC3339)
+	}
+
+	fmt.Fprintf(w, "    expires: %s\n", expires)
+
+	if len(r.Scopes) > 0 {
+		fmt.Fprintf(w, "     scopes:\n")
+
+		for _, scope := range r.Scopes {
+			fmt.Fprintf(w, "  - %s: %s\n", scope.Repository, strings.Join(scope.Actions, ", "))
+		}
+	}
+
+	if len(r.AllowedIPs) > 0 {
+		fmt.Fprintf(w, "allowed-ips: %s\n", strings.Join(r.AllowedIPs, ", "))
+	}
+
+	if r.OIDCIssuer != "" {
+		fmt.Fprintf(w, "oidc-issuer: %s\n", r.OIDCIssuer)
+	}
+
+	if len(r.OIDCSubjects) > 0 {
+		fmt.Fprintf(w, "oidc-subjects: %s\n", strings.Join(r.OIDCSubjects, ", "))
+	}
+}
+
+type machineAccountResult struct {
+	Namespace string     `json:"namespace"`
+	Name      string     `json:"name"`
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	types.MachineAccountRestrictions
+
+	Export *credentialExport `json:"export,omitempty"`
+}
+
+func (r *machineAccountResult) WriteText(w io.Writer) error {
+	if r.Export != nil {
+		return r.Export.WriteText(w)
+	}
+
+	fmt.Fprintf(w, "Machine account created!\n")
+	writeRestrictions(w, &r.MachineAccountRestrictions, r.ExpiresAt)
+
+	_, err := fmt.Fprintf(w, "Token for account: %s\n", r.Token)
+	return err
+}
+
+func (c *CLI) createMachineF(ctx context.Context, opts struct {
+	Namespace   string        `short:"n" long:"namespace" description:"initial namespace to reserve"`
+	Name        string        `long:"name" description:"name for machine account"`
+	Description string        `short:"d" long:"description" description:"description of machine account"`
+	Write       bool          `long:"enable-write" description:"allow the account to have write access"`
+	Scopes      []string      `long:"scope" description:"limit the account to repository:action[,action...] (pull, push, delete, admin), may be repeated"`
+	TTL         time.Duration `long:"ttl" description:"how long until the account expires, eg 720h, rounded up to whole seconds; never when not set"`
+	AllowIPs    []string      `long:"allow-ip" description:"only accept the token from this address or CIDR range, may be repeated"`
+	OIDCIssuer  string        `long:"oidc-issuer" description:"only accept the token alongside an OIDC token from this issuer"`
+	OIDCSubject []string      `long:"oidc-subject" description:"only accept the token alongside an OIDC token with this subject, may be repeated"`
+
+	Export exportOptions `group:"Export Options"`
+}) (*machineAccountResult, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("namespace (-n) is required")
+	}
+
+	err := opts.Export.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Write && len(opts.Scopes) > 0 {
+		return nil, fmt.Errorf("--enable-write grants write access to every repository, use push scopes instead when using --scope")
+	}
+
+	// The API counts whole seconds and takes 0 to mean the account never
+	// expires, so shorter TTLs mustn't round down to it.
+	if opts.TTL < 0 {
+		return nil, fmt.Errorf("--ttl must be positive")
+	}
+
+	if opts.TTL > 0 && opts.TTL < time.Second {
+		return nil, fmt.Errorf("--ttl must be at least 1s")
+	}
+
+	if len(opts.OIDCSubject) > 0 && opts.OIDCIssuer == "" {
+		return nil, fmt.Errorf("--oidc-issuer is required with --oidc-subject")
+	}
+
+	var restrict types.MachineAccountRestrictions
+
+	for _, s := range opts.Scopes {
+		scope, err := parseScope(s)
+		if err != nil {
+			return nil, err
+		}
+
+		restrict.Scopes = append(restrict.Scopes, scope)
+	}
+
+	for _, s := range opts.AllowIPs {
+		cidr, err := parseAllowedIP(s)
+		if err != nil {
+			return nil, err
+		}
+
+		restrict.AllowedIPs = append(restrict.AllowedIPs, cidr)
+	}
+
+	restrict.OIDCIssuer = opts.OIDCIssuer
+	restrict.OIDCSubjects = opts.OIDCSubject
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = fmt.Sprintf("machine-%s", uuid.New().String())
+	}
+
+	statusf(ctx, "Creating machine account '%s'...\n", name)
+
+	tv, err := apiClient(ctx, prof).CreateMachineAccount(ctx, opts.Namespace, &types.MachineAccountCreateRequest{
+		Name:                       name,
+		Description:                opts.Description,
+		Write:                      opts.Write,
+		TTL:                        int64((opts.TTL + time.Second - 1) / time.Second),
+		MachineAccountRestrictions: restrict,
+	})
+	if err != nil {
+		return nil, permissionError(err, "creating machine accounts", opts.Namespace, "admin")
+	}
+
+	result := &machineAccountResult{
+		Namespace:                  opts.Namespace,
+		Name:                       name,
+		Token:                      tv.Token,
+		ExpiresAt:                  tv.ExpiresAt,
+		MachineAccountRestrictions: restrict,
+	}
+
+	result.Export, err = opts.Export.export(&exportCreds{
+		Name:     name,
+		Registry: prof.RegistryHost(),
+		Token:    tv.Token,
+	})
+	if err != nil {
+		return nil, exportFailed(err, tv.Token)
+	}
+
+	return result, nil
+}
+
+type machineAccountInfo types.MachineAccountInfo
+
+func (m *machineAccountInfo) WriteText(w io.Writer) error {
+	lastUsed := "never"
+	if m.LastUsedAt != nil {
+		lastUsed = m.LastUsedAt.Format(time.RFC3339)
+	}
+
+	_, err := fmt.Fprintf(w, "[machine-account]\n       name: %s\ndescription: %s\n      write: %t\n    created: %s\n  last-used: %s\n",
+		m.Name,
+		m.Description,
+		m.Write,
+		m.CreatedAt.Format(time.RFC3339),
+		lastUsed,
+	)
+
+	writeRestrictions(w, &m.MachineAccountRestrictions, m.ExpiresAt)
+
+	return err
+}
+
+type machineAccountList types.ListMachineAccounts
+
+func (l *machineAccountList) WriteText(w io.Writer) error {
+	if len(l.MachineAccounts) == 0 {
+		_, err := fmt.Fprintln(w, "No machine accounts.")
+		return err
+	}
+
+	for i := range l.MachineAccounts {
+		err := (*machineAccountInfo)(&l.MachineAccounts[i]).WriteText(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// machineAccountArgs are the arguments shared by the commands operating on a
+// single machine account.
+type machineAccountArgs struct {
+	Namespace string `short:"n" long:"namespace" description:"namespace of the machine account"`
+
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}
+
+func (a *machineAccountArgs) validate() error {
+	return requireMachineAccount(a.Namespace, a.Pos.Name)
+}
+
+func requireMachineAccount(namespace, name string) error {
+	if namespace == "" {
+		return fmt.Errorf("namespace (-n) is required")
+	}
+
+	if name == "" {
+		return fmt.Errorf("requires machine account name as argument")
+	}
+
+	return nil
+}
+
+func (c *CLI) listMachineF(ctx context.Context, opts struct {
+	Namespace string `short:"n" long:"namespace" description:"namespace to list machine accounts of"`
+}) (*machineAccountList, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("namespace (-n) is required")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	list, err := apiClient(ctx, prof).MachineAccounts(ctx, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*machineAccountList)(list), nil
+}
+
+func (c *CLI) showMachineF(ctx context.Context, opts machineAccountArgs) (*machineAccountInfo, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	info, err := apiClient(ctx, prof).MachineAccount(ctx, opts.Namespace, opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*machineAccountInfo)(info), nil
+}
+
+type machineTokenResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Token     string `json:"token,omitempty"`
+	Revoked   bool   `json:"revoked,omitempty"`
+
+	Export *credentialExport `json:"export,omitempty"`
+}
+
+func (r *machineTokenResult) WriteText(w io.Writer) error {
+	if r.Export != nil {
+		return r.Export.WriteText(w)
+	}
+
+	if r.Revoked {
+		_, err := fmt.Fprintf(w, "Machine account %s revoked.\n", r.Name)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Token rotated, the previous one no longer works.\nToken for account: %s\n", r.Token)
+	return err
+}
+
+func (c *CLI) rotateMachineF(ctx context.Context, opts struct {
+	Namespace string `short:"n" long:"namespace" description:"namespace of the machine account"`
+
+	Export exportOptions `group:"Export Options"`
+
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*machineTokenResult, error) {
+	err := requireMachineAccount(opts.Namespace, opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = opts.Export.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	statusf(ctx, "Rotating token of machine account '%s'...\n", opts.Pos.Name)
+
+	tv, err := apiClient(ctx, prof).RotateMachineAccount(ctx, opts.Namespace, opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &machineTokenResult{
+		Namespace: opts.Namespace,
+		Name:      opts.Pos.Name,
+		Token:     tv.Token,
+	}
+
+	result.Export, err = opts.Export.export(&exportCreds{
+		Name:     opts.Pos.Name,
+		Registry: prof.RegistryHost(),
+		Token:    tv.Token,
+	})
+	if err != nil {
+		return nil, exportFailed(err, tv.Token)
+	}
+
+	return result, nil
+}
+
+func (c *CLI) revokeMachineF(ctx context.Context, opts machineAccountArgs) (*machineTokenResult, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	statusf(ctx, "Revoking machine account '%s'...\n", opts.Pos.Name)
+
+	err = apiClient(ctx, prof).RevokeMachineAccount(ctx, opts.Namespace, opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &machineTokenResult{
+		Namespace: opts.Namespace,
+		Name:      opts.Pos.Name,
+		Revoked:   true,
+	}, nil
+}
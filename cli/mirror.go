@@ -0,0 +1,371 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// mirrorConfig is the file read by vcr mirror sync. For example:
+//
+//	namespace = "acme"
+//
+//	[[mirror]]
+//	source = "docker.io/library/alpine"
+//	tags = ["3.*", "latest"]
+//	exclude = ["*-rc*"]
+//
+//	[[mirror]]
+//	source = "quay.io/prometheus/node-exporter"
+//	destination = "acme/base/node-exporter"
+//	tags = ["v1.*"]
+type mirrorConfig struct {
+	// Namespace is where mirrors without a destination are copied to,
+	// under the last element of their source.
+	Namespace string `toml:"namespace"`
+
+	Mirrors []mirrorEntry `toml:"mirror"`
+}
+
+type mirrorEntry struct {
+	Source      string   `toml:"source"`
+	Destination string   `toml:"destination"`
+	Tags        []string `toml:"tags"`
+	Exclude     []string `toml:"exclude"`
+}
+
+// loadMirrorConfig reads and validates a mirror file.
+func loadMirrorConfig(file string) (*mirrorConfig, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg mirrorConfig
+
+	_, err = toml.Decode(string(data), &cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", file)
+	}
+
+	if len(cfg.Mirrors) == 0 {
+		return nil, fmt.Errorf("%s doesn't list any mirrors", file)
+	}
+
+	for i, m := range cfg.Mirrors {
+		if m.Source == "" {
+			return nil, fmt.Errorf("mirror %d in %s has no source", i+1, file)
+		}
+
+		// Mirroring every tag of a popular image by accident is expensive,
+		// so "*" has to be asked for.
+		if len(m.Tags) == 0 {
+			return nil, fmt.Errorf("mirror of %s has no tags, use [\"*\"] for all of them", m.Source)
+		}
+
+		if m.Destination == "" && cfg.Namespace == "" {
+			return nil, fmt.Errorf("mirror of %s has no destination and there's no default namespace", m.Source)
+		}
+
+		for _, pat := range append(m.Tags, m.Exclude...) {
+			if _, err := path.Match(pat, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q for %s", pat, m.Source)
+			}
+		}
+	}
+
+	return &cfg, nil
+}
+
+// repos returns the source and destination repositories of m. Sources are
+// upstream images so, like docker, they default to Docker Hub; destinations
+// default to the profile's registry.
+func (m *mirrorEntry) repos(prof *Profile, namespace string) (name.Repository, name.Repository, error) {
+	src, err := name.NewRepository(m.Source)
+	if err != nil {
+		return src, name.Repository{}, errors.Wrapf(err, "invalid source %s", m.Source)
+	}
+
+	dest := m.Destination
+	if dest == "" {
+		dest = namespace + "/" + path.Base(src.RepositoryStr())
+	}
+
+	dst, err := parseRepo(prof, dest)
+
+	return src, dst, err
+}
+
+// tags returns the upstream tags selected by m.
+func (m *mirrorEntry) tags(src name.Repository, ropts []remote.Option) ([]string, error) {
+	include, err := newTagFilter(m.Tags, "")
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := newTagFilter(m.Exclude, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := remote.List(src, ropts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing tags of %s", src)
+	}
+
+	var selected []string
+
+	for _, tag := range tags {
+		if isCosignTag(tag) || !include.matchName(tag) {
+			continue
+		}
+
+		if len(m.Exclude) > 0 && exclude.matchName(tag) {
+			continue
+		}
+
+		selected = append(selected, tag)
+	}
+
+	sort.Strings(selected)
+
+	return selected, nil
+}
+
+const (
+	mirrorCopied  = "copied"
+	mirrorSkipped = "skipped"
+	mirrorPending = "would copy"
+	mirrorFailed  = "failed"
+)
+
+type mirrorSyncEntry struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Digest      string   `json:"digest,omitempty"`
+	Status      string   `json:"status"`
+	Attached    []string `json:"attached,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type mirrorSyncReport struct {
+	Copied  int                `json:"copied"`
+	Skipped int                `json:"skipped"`
+	Pending int                `json:"pending,omitempty"`
+	Failed  int                `json:"failed"`
+	Entries []*mirrorSyncEntry `json:"entries"`
+}
+
+func (r *mirrorSyncReport) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "[mirror sync]\n   copied: %d\n  skipped: %d\n", r.Copied, r.Skipped)
+
+	if r.Pending > 0 {
+		fmt.Fprintf(w, "  pending: %d\n", r.Pending)
+	}
+
+	fmt.Fprintf(w, "   failed: %d\n", r.Failed)
+
+	for _, status := range []string{mirrorCopied, mirrorPending, mirrorFailed} {
+		var shown bool
+
+		for _, e := range r.Entries {
+			if e.Status != status {
+				continue
+			}
+
+			if !shown {
+				fmt.Fprintf(w, "\n[%s]\n", status)
+				shown = true
+			}
+
+			switch status {
+			case mirrorFailed:
+				fmt.Fprintf(w, "  %s => %s\n    %s\n", e.Source, e.Destination, e.Error)
+			default:
+				fmt.Fprintf(w, "  %s => %s@%s\n", e.Source, e.Destination, e.Digest)
+
+				for _, t := range e.Attached {
+					fmt.Fprintf(w, "    attached: %s\n", t)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *mirrorSyncReport) add(e *mirrorSyncEntry) {
+	switch e.Status {
+	case mirrorCopied:
+		r.Copied++
+	case mirrorSkipped:
+		r.Skipped++
+	case mirrorPending:
+		r.Pending++
+	case mirrorFailed:
+		r.Failed++
+	}
+
+	r.Entries = append(r.Entries, e)
+}
+
+type mirrorJob struct {
+	src, dst name.Reference
+	entry    *mirrorSyncEntry
+}
+
+// run copies, or with dryRun only checks, a single tag.
+func (j *mirrorJob) run(cp *copier, dryRun bool) {
+	e := j.entry
+
+	if dryRun {
+		digest, present, err := cp.present(j.src, j.dst)
+		switch {
+		case err != nil:
+			e.Status, e.Error = mirrorFailed, err.Error()
+		case present:
+			e.Status, e.Digest = mirrorSkipped, digest.String()
+		default:
+			e.Status, e.Digest = mirrorPending, digest.String()
+		}
+
+		return
+	}
+
+	res, err := cp.copy(j.src, j.dst, false)
+	if res != nil {
+		e.Digest = res.Digest
+		e.Attached = res.Attached
+	}
+
+	switch {
+	case err != nil:
+		e.Status, e.Error = mirrorFailed, err.Error()
+	case res.Skipped && len(res.Attached) == 0:
+		e.Status = mirrorSkipped
+	default:
+		e.Status = mirrorCopied
+	}
+}
+
+func (c *CLI) mirrorSyncF(ctx context.Context, opts struct {
+	File         string `short:"f" long:"file" required:"true" description:"TOML file listing the images to mirror"`
+	Parallel     int    `short:"p" long:"parallel" default:"4" description:"how many tags to copy at once"`
+	DryRun       bool   `long:"dry-run" description:"only show what would be copied"`
+	NoSignatures bool   `long:"no-signatures" description:"don't copy the signatures and attestations attached by cosign"`
+}) (*mirrorSyncReport, error) {
+	if opts.Parallel < 1 {
+		return nil, fmt.Errorf("--parallel must be at least 1")
+	}
+
+	cfg, err := loadMirrorConfig(opts.File)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	cp := &copier{
+		ropts:      registryOptions(ctx, prof, defaultJobs),
+		signatures: !opts.NoSignatures,
+		status: func(format string, args ...interface{}) {
+			statusf(ctx, format, args...)
+		},
+	}
+
+	var (
+		report mirrorSyncReport
+		jobs   []*mirrorJob
+	)
+
+	// Failing to list one source doesn't stop the others from syncing.
+	for i := range cfg.Mirrors {
+		m := &cfg.Mirrors[i]
+
+		src, dst, err := m.repos(prof, cfg.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		statusf(ctx, "Listing tags of %s...\n", src)
+
+		tags, err := m.tags(src, cp.ropts)
+		if err != nil {
+			report.Entries = append(report.Entries, &mirrorSyncEntry{
+				Source:      src.String(),
+				Destination: dst.String(),
+				Status:      mirrorFailed,
+				Error:       err.Error(),
+			})
+
+			continue
+		}
+
+		for _, tag := range tags {
+			e := &mirrorSyncEntry{
+				Source:      src.Tag(tag).String(),
+				Destination: dst.Tag(tag).String(),
+			}
+
+			report.Entries = append(report.Entries, e)
+			jobs = append(jobs, &mirrorJob{src: src.Tag(tag), dst: dst.Tag(tag), entry: e})
+		}
+	}
+
+	queue := make(chan *mirrorJob)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.Parallel && i < len(jobs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range queue {
+				j.run(cp, opts.DryRun)
+			}
+		}()
+	}
+
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			j.entry.Status, j.entry.Error = mirrorFailed, ctx.Err().Error()
+			continue
+		}
+
+		queue <- j
+	}
+
+	close(queue)
+	wg.Wait()
+
+	entries := report.Entries
+	report.Entries = nil
+
+	for _, e := range entries {
+		report.add(e)
+	}
+
+	if report.Failed > 0 {
+		return &report, exitCode(ExitError)
+	}
+
+	return &report, nil
+}
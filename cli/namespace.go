@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lab47/labctl/pkg/client"
+	"github.com/lab47/labctl/types"
+)
+
+// parseMetadata parses key=value pairs. An empty value is kept so that the
+// key is removed when updating metadata.
+func parseMetadata(pairs []string) (map[string]string, error) {
+	md := map[string]string{}
+
+	for _, pair := range pairs {
+		idx := strings.IndexByte(pair, '=')
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid metadata %q, expected key=value", pair)
+		}
+
+		md[pair[:idx]] = pair[idx+1:]
+	}
+
+	return md, nil
+}
+
+func writeMetadata(w io.Writer, md map[string]string) {
+	var keys []string
+
+	for k := range md {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "  - %s: %s\n", k, md[k])
+	}
+}
+
+type namespaceResult struct {
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+func (r *namespaceResult) WriteText(w io.Writer) error {
+	if r.Deleted {
+		_, err := fmt.Fprintf(w, "Namespace deleted: %s\n", r.Name)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Namespace created: %s\n", r.Name)
+	return err
+}
+
+func (c *CLI) createNamespaceF(ctx context.Context, opts struct {
+	Metadata []string `short:"m" long:"metadata" description:"metadata to set on the namespace as key=value, may be repeated"`
+
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*namespaceResult, error) {
+	if opts.Pos.Name == "" {
+		return nil, fmt.Errorf("requires namespace name as argument")
+	}
+
+	md, err := parseMetadata(opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	statusf(ctx, "Creating namespace...\n")
+
+	info := &types.NamespaceInfo{Name: opts.Pos.Name}
+	if len(md) > 0 {
+		info.Metadata = md
+	}
+
+	err = apiClient(ctx, prof).CreateNamespace(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &namespaceResult{Name: opts.Pos.Name}, nil
+}
+
+type namespaceDetails types.NamespaceDetails
+
+func (d *namespaceDetails) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "[namespace]\n    name: %s\n credits: $%s\n", d.Name, d.Credit)
+
+	if len(d.Metadata) > 0 {
+		fmt.Fprintf(w, "metadata:\n")
+		writeMetadata(w, d.Metadata)
+	}
+
+	fmt.Fprintf(w, "   repos:\n")
+
+	for _, re := range d.Repos {
+		fmt.Fprintf(w, "  - name: %s\n    created_at: %s\n    tags: %d\n",
+			re.Name,
+			re.CreatedAt.Format(time.RFC3339),
+			re.TotalTags,
+		)
+	}
+
+	return nil
+}
+
+func (c *CLI) describeNamespaceF(ctx context.Context, opts struct {
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*namespaceDetails, error) {
+	if opts.Pos.Name == "" {
+		return nil, fmt.Errorf("requires namespace name as argument")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	nd, err := apiClient(ctx, prof).Namespace(ctx, opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*namespaceDetails)(nd), nil
+}
+
+func (c *CLI) deleteNamespaceF(ctx context.Context, opts struct {
+	Force   bool   `short:"f" long:"force" description:"delete the namespace even if it has repositories, deleting them too"`
+	Confirm string `long:"confirm" description:"name of the namespace, to confirm deletion without being prompted"`
+
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*namespaceResult, error) {
+	name := opts.Pos.Name
+	if name == "" {
+		return nil, fmt.Errorf("requires namespace name as argument")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	api := apiClient(ctx, prof)
+
+	nd, err := api.Namespace(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nd.Repos) > 0 {
+		if !opts.Force {
+			return nil, &client.Error{
+				Kind: client.KindConflict,
+				Err:  fmt.Errorf("namespace %s still has %d repositories, use --force to delete them along with it", name, len(nd.Repos)),
+			}
+		}
+
+		statusf(ctx, "Deleting namespace %s will also delete %d repositories!\n", name, len(nd.Repos))
+	}
+
+	confirm := opts.Confirm
+	if confirm == "" {
+		confirm, err = readLine(fmt.Sprintf("Type the name of the namespace (%s) to confirm deletion: ", name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if confirm != name {
+		return nil, fmt.Errorf("confirmation did not match the namespace name, not deleting")
+	}
+
+	statusf(ctx, "Deleting namespace...\n")
+
+	err = api.DeleteNamespace(ctx, name, opts.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	return &namespaceResult{Name: name, Deleted: true}, nil
+}
+
+type namespaceMetadata types.NamespaceInfo
+
+func (m *namespaceMetadata) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Metadata of %s:\n", m.Name)
+	writeMetadata(w, m.Metadata)
+
+	return nil
+}
+
+func (c *CLI) setNamespaceMetadataF(ctx context.Context, opts struct {
+	Pos struct {
+		Name  string   `positional-arg-name:"name"`
+		Pairs []string `positional-arg-name:"key=value"`
+	} `positional-args:"yes"`
+}) (*namespaceMetadata, error) {
+	if opts.Pos.Name == "" {
+		return nil, fmt.Errorf("requires namespace name as argument")
+	}
+
+	if len(opts.Pos.Pairs) == 0 {
+		return nil, fmt.Errorf("requires metadata to set as key=value arguments, use key= to remove a key")
+	}
+
+	md, err := parseMetadata(opts.Pos.Pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	info, err := apiClient(ctx, prof).UpdateNamespaceMetadata(ctx, opts.Pos.Name, md)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Name == "" {
+		info.Name = opts.Pos.Name
+	}
+
+	return (*namespaceMetadata)(info), nil
+}
@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lab47/labctl/types"
+)
+
+// namespaceRoles are the roles a member of a namespace can have, along with
+// what they allow.
+var namespaceRoles = []struct {
+	name, allows string
+}{
+	{"viewer", "pull from repositories"},
+	{"developer", "pull from and push to repositories"},
+	{"admin", "manage repositories, machine accounts and members"},
+	{"billing", "add credit"},
+}
+
+func validateRole(role string) error {
+	var sb strings.Builder
+
+	for _, r := range namespaceRoles {
+		if r.name == role {
+			return nil
+		}
+
+		fmt.Fprintf(&sb, "\n  %-9s  %s", r.name, r.allows)
+	}
+
+	return fmt.Errorf("unknown role '%s', must be one of:%s", role, sb.String())
+}
+
+type memberList types.ListNamespaceMembers
+
+func (l *memberList) WriteText(w io.Writer) error {
+	if len(l.Members) == 0 {
+		_, err := fmt.Fprintln(w, "No members.")
+		return err
+	}
+
+	for _, m := range l.Members {
+		status := "member"
+		since := m.JoinedAt
+
+		if m.Pending {
+			status = "invited"
+			since = m.InvitedAt
+		}
+
+		fmt.Fprintf(w, "[member]\n  email: %s\n   role: %s\n status: %s\n", m.Email, m.Role, status)
+
+		if since != nil {
+			fmt.Fprintf(w, "  since: %s\n", since.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+func (c *CLI) membersListF(ctx context.Context, opts struct {
+	Namespace string `short:"n" long:"namespace" description:"namespace to list the members of"`
+}) (*memberList, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("namespace (-n) is required")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	list, err := apiClient(ctx, prof).Members(ctx, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*memberList)(list), nil
+}
+
+type memberResult struct {
+	Namespace string     `json:"namespace"`
+	Email     string     `json:"email,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	Code      string     `json:"code,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Removed   bool       `json:"removed,omitempty"`
+
+	joined bool
+}
+
+func (r *memberResult) WriteText(w io.Writer) error {
+	var err error
+
+	switch {
+	case r.joined:
+		_, err = fmt.Fprintf(w, "Joined namespace %s as %s!\n", r.Namespace, r.Role)
+	case r.Removed:
+		_, err = fmt.Fprintf(w, "Removed %s from namespace %s.\n", r.Email, r.Namespace)
+	case r.Code != "":
+		_, err = fmt.Fprintf(w, "Invited %s to namespace %s as %s.\nThey can accept with: labctl namespace join %s\n",
+			r.Email, r.Namespace, r.Role, r.Code)
+	default:
+		_, err = fmt.Fprintf(w, "%s is now %s in namespace %s.\n", r.Email, r.Role, r.Namespace)
+	}
+
+	return err
+}
+
+// memberArgs are the arguments shared by the commands operating on a member
+// of a namespace.
+type memberArgs struct {
+	Namespace string `short:"n" long:"namespace" description:"namespace of the member"`
+	Role      string `short:"r" long:"role" description:"role of the member: viewer, developer, admin or billing"`
+
+	Pos struct {
+		Email string `positional-arg-name:"email"`
+	} `positional-args:"yes"`
+}
+
+func (a *memberArgs) validate(needRole bool) error {
+	if a.Namespace == "" {
+		return fmt.Errorf("namespace (-n) is required")
+	}
+
+	if a.Pos.Email == "" {
+		return fmt.Errorf("requires email of member as argument")
+	}
+
+	if needRole {
+		if a.Role == "" {
+			return fmt.Errorf("role (-r) is required")
+		}
+
+		return validateRole(a.Role)
+	}
+
+	return nil
+}
+
+func (c *CLI) membersInviteF(ctx context.Context, opts memberArgs) (*memberResult, error) {
+	if opts.Role == "" {
+		opts.Role = "viewer"
+	}
+
+	err := opts.validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	statusf(ctx, "Inviting %s...\n", opts.Pos.Email)
+
+	resp, err := apiClient(ctx, prof).InviteMember(ctx, opts.Namespace, &types.NamespaceInviteRequest{
+		Email: opts.Pos.Email,
+		Role:  opts.Role,
+	})
+	if err != nil {
+		return nil, permissionError(err, "inviting members", opts.Namespace, "admin")
+	}
+
+	return &memberResult{
+		Namespace: opts.Namespace,
+		Email:     opts.Pos.Email,
+		Role:      opts.Role,
+		Code:      resp.Code,
+		ExpiresAt: resp.ExpiresAt,
+	}, nil
+}
+
+func (c *CLI) membersSetRoleF(ctx context.Context, opts memberArgs) (*memberResult, error) {
+	err := opts.validate(true)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	err = apiClient(ctx, prof).SetMemberRole(ctx, opts.Namespace, opts.Pos.Email, opts.Role)
+	if err != nil {
+		return nil, permissionError(err, "changing roles", opts.Namespace, "admin")
+	}
+
+	return &memberResult{
+		Namespace: opts.Namespace,
+		Email:     opts.Pos.Email,
+		Role:      opts.Role,
+	}, nil
+}
+
+func (c *CLI) membersRemoveF(ctx context.Context, opts struct {
+	Namespace string `short:"n" long:"namespace" description:"namespace of the member"`
+
+	Pos struct {
+		Email string `positional-arg-name:"email"`
+	} `positional-args:"yes"`
+}) (*memberResult, error) {
+	if opts.Namespace == "" {
+		return nil, fmt.Errorf("namespace (-n) is required")
+	}
+
+	if opts.Pos.Email == "" {
+		return nil, fmt.Errorf("requires email of member as argument")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	err = apiClient(ctx, prof).RemoveMember(ctx, opts.Namespace, opts.Pos.Email)
+	if err != nil {
+		return nil, permissionError(err, "removing members", opts.Namespace, "admin")
+	}
+
+	return &memberResult{
+		Namespace: opts.Namespace,
+		Email:     opts.Pos.Email,
+		Removed:   true,
+	}, nil
+}
+
+func (c *CLI) joinNamespaceF(ctx context.Context, opts struct {
+	Pos struct {
+		Code string `positional-arg-name:"code"`
+	} `positional-args:"yes"`
+}) (*memberResult, error) {
+	if opts.Pos.Code == "" {
+		return nil, fmt.Errorf("requires invitation code as argument")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	resp, err := apiClient(ctx, prof).JoinNamespace(ctx, opts.Pos.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memberResult{
+		Namespace: resp.Namespace,
+		Role:      resp.Role,
+		joined:    true,
+	}, nil
+}
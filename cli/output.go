@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// textWriter is implemented by command results that have a human friendly
+// rendering. Results without one are shown as YAML in text mode.
+type textWriter interface {
+	WriteText(w io.Writer) error
+}
+
+// output renders the results of commands in the format selected with
+// --output.
+type output struct {
+	format string
+	tmpl   *template.Template
+}
+
+func newOutput(spec string) (*output, error) {
+	format := spec
+
+	if idx := strings.IndexByte(spec, '='); idx != -1 {
+		format = spec[:idx]
+	}
+
+	switch format {
+	case "", "text":
+		return &output{format: "text"}, nil
+	case "json", "yaml":
+		return &output{format: format}, nil
+	case "template":
+		if !strings.Contains(spec, "=") {
+			return nil, fmt.Errorf("template output requires a template, eg: -o template='{{.Name}}'")
+		}
+
+		tmpl, err := template.New("output").Parse(spec[len("template="):])
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing output template")
+		}
+
+		return &output{format: format, tmpl: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format '%s', must be one of: text, json, yaml, template", format)
+	}
+}
+
+func (o *output) render(w io.Writer, result interface{}) error {
+	if result == nil {
+		return nil
+	}
+
+	if rv := reflect.ValueOf(result); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	switch o.format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case "template":
+		err := o.tmpl.Execute(w, result)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(w)
+		return err
+	case "text":
+		if tw, ok := result.(textWriter); ok {
+			return tw.WriteText(w)
+		}
+	}
+
+	data, err := yaml.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// structuredOutput reports if the running command renders its result in a
+// machine readable format.
+func structuredOutput(ctx context.Context) bool {
+	out := globalOptions(ctx).Output
+	return out != "" && out != "text"
+}
+
+// statusf prints progress information for the user. It goes to stderr when
+// the result is machine readable, so as not to mix with it.
+func statusf(ctx context.Context, format string, args ...interface{}) {
+	w := os.Stdout
+	if structuredOutput(ctx) {
+		w = os.Stderr
+	}
+
+	fmt.Fprintf(w, format, args...)
+}
@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type profileInfo struct {
+	Name     string `json:"name"`
+	Current  bool   `json:"current"`
+	API      string `json:"api_base"`
+	Registry string `json:"registry"`
+	Email    string `json:"email,omitempty"`
+	Token    string `json:"token"`
+	Retries  int    `json:"retries"`
+}
+
+func newProfileInfo(cfg *Config, prof *Profile) *profileInfo {
+	return &profileInfo{
+		Name:     prof.Name,
+		Current:  prof.Name == cfg.CurrentProfile(),
+		API:      prof.API(),
+		Registry: prof.RegistryHost(),
+		Email:    prof.Email,
+		Token:    maskToken(prof.Token),
+		Retries:  prof.retries(),
+	}
+}
+
+func (p *profileInfo) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "[profile]\n     name: %s\n api-base: %s\n registry: %s\n    email: %s\n    token: %s\n  retries: %d\n",
+		p.Name, p.API, p.Registry, p.Email, p.Token, p.Retries)
+	return err
+}
+
+type profileList []*profileInfo
+
+func (l profileList) WriteText(w io.Writer) error {
+	for _, prof := range l {
+		marker := " "
+		if prof.Current {
+			marker = "*"
+		}
+
+		fmt.Fprintf(w, "%s %s (%s)\n", marker, prof.Name, prof.API)
+	}
+
+	return nil
+}
+
+func (c *CLI) profileListF(ctx context.Context, opts struct {
+}) (profileList, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var list profileList
+
+	for _, name := range cfg.ProfileNames() {
+		prof, _ := cfg.Lookup(name)
+		list = append(list, newProfileInfo(cfg, prof))
+	}
+
+	return list, nil
+}
+
+type profileChange struct {
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+func (p *profileChange) WriteText(w io.Writer) error {
+	if p.Deleted {
+		_, err := fmt.Fprintf(w, "Deleted profile '%s'\n", p.Name)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Now using profile '%s'\n", p.Name)
+	return err
+}
+
+func (c *CLI) profileUseF(ctx context.Context, opts struct {
+	APIBase  string `long:"api-base" description:"base URL of the lab47 API for the profile"`
+	Registry string `long:"registry" description:"host of the OCI registry for the profile"`
+	Retries  *int   `long:"retries" description:"how many times to retry failed requests"`
+
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*profileChange, error) {
+	if opts.Pos.Name == "" {
+		return nil, fmt.Errorf("requires profile name as argument")
+	}
+
+	if opts.APIBase != "" {
+		err := checkAPIBase(opts.APIBase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := cfg.Lookup(opts.Pos.Name); !ok {
+		statusf(ctx, "Creating profile '%s'...\n", opts.Pos.Name)
+	}
+
+	prof := cfg.Profile(opts.Pos.Name)
+
+	if opts.APIBase != "" {
+		prof.APIBase = strings.TrimRight(opts.APIBase, "/")
+	}
+
+	if opts.Registry != "" {
+		prof.Registry = opts.Registry
+	}
+
+	if opts.Retries != nil {
+		prof.Retries = opts.Retries
+	}
+
+	cfg.Current = opts.Pos.Name
+
+	err = SaveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profileChange{Name: opts.Pos.Name}, nil
+}
+
+func (c *CLI) profileShowF(ctx context.Context, opts struct {
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*profileInfo, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	name := opts.Pos.Name
+	if name == "" {
+		name = globalOptions(ctx).Profile
+	}
+
+	if name == "" {
+		name = cfg.CurrentProfile()
+	}
+
+	prof, ok := cfg.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown profile: %s", name)
+	}
+
+	return newProfileInfo(cfg, prof), nil
+}
+
+func (c *CLI) profileDeleteF(ctx context.Context, opts struct {
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*profileChange, error) {
+	if opts.Pos.Name == "" {
+		return nil, fmt.Errorf("requires profile name as argument")
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := cfg.Lookup(opts.Pos.Name); !ok {
+		return nil, fmt.Errorf("unknown profile: %s", opts.Pos.Name)
+	}
+
+	delete(cfg.Profiles, opts.Pos.Name)
+
+	if cfg.Current == opts.Pos.Name {
+		cfg.Current = ""
+	}
+
+	err = SaveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profileChange{Name: opts.Pos.Name, Deleted: true}, nil
+}
+
+// maskToken hides all but the last few characters of a token so that it can
+// be displayed safely.
+func maskToken(token string) string {
+	if token == "" {
+		return "<none>"
+	}
+
+	if len(token) <= 8 {
+		return strings.Repeat("*", len(token))
+	}
+
+	return strings.Repeat("*", 8) + token[len(token)-4:]
+}
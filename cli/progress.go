@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/term"
+)
+
+// progress reports how many bytes of a transfer are done on stderr. It only
+// draws when stderr is a terminal, otherwise it stays silent.
+type progress struct {
+	label string
+	w     io.Writer
+
+	total int64
+	done  int64
+
+	stop    chan struct{}
+	wait    chan struct{}
+	drained chan struct{}
+}
+
+func newProgress(label string, total int64) *progress {
+	p := &progress{
+		label: label,
+		total: total,
+		stop:  make(chan struct{}),
+		wait:  make(chan struct{}),
+	}
+
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		close(p.wait)
+		return p
+	}
+
+	p.w = os.Stderr
+
+	go p.run()
+
+	return p
+}
+
+func (p *progress) run() {
+	defer close(p.wait)
+
+	tick := time.NewTicker(250 * time.Millisecond)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			p.draw()
+			fmt.Fprintln(p.w)
+			return
+		case <-tick.C:
+			p.draw()
+		}
+	}
+}
+
+func (p *progress) draw() {
+	done := atomic.LoadInt64(&p.done)
+	total := atomic.LoadInt64(&p.total)
+
+	if total <= 0 {
+		fmt.Fprintf(p.w, "\r%s %s", p.label, formatSize(done))
+		return
+	}
+
+	if done > total {
+		done = total
+	}
+
+	fmt.Fprintf(p.w, "\r%s %s / %s (%d%%)   ", p.label, formatSize(done), formatSize(total), done*100/total)
+}
+
+// add records n more bytes as done.
+func (p *progress) add(n int64) {
+	atomic.AddInt64(&p.done, n)
+}
+
+// set records the absolute state of the transfer.
+func (p *progress) set(done, total int64) {
+	atomic.StoreInt64(&p.done, done)
+	atomic.StoreInt64(&p.total, total)
+}
+
+// finish stops drawing, leaving the final state on screen. err is the
+// result of the transfer: go-containerregistry only closes the updates
+// channel when the transfer got going, so it's only waited on after a
+// success.
+func (p *progress) finish(err error) {
+	if p.drained != nil && err == nil {
+		<-p.drained
+	}
+
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+
+	<-p.wait
+}
+
+// updates returns a channel for remote.WithProgress that feeds p. The
+// channel is closed by go-containerregistry once the write is done.
+func (p *progress) updates() chan<- gv1.Update {
+	ch := make(chan gv1.Update, 100)
+	p.drained = make(chan struct{})
+
+	go func() {
+		defer close(p.drained)
+
+		for u := range ch {
+			if u.Error == nil {
+				p.set(u.Complete, u.Total)
+			}
+		}
+	}()
+
+	return ch
+}
+
+// countingTransport adds the bytes of every response body read to a progress.
+type countingTransport struct {
+	base http.RoundTripper
+	p    *progress
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &countingReader{ReadCloser: resp.Body, p: t.p}
+
+	return resp, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	p *progress
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.p.add(int64(n))
+	return n, err
+}
@@ -0,0 +1,333 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+)
+
+// refNameAnnotation names the images within an OCI layout.
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// defaultJobs is how many blobs are transferred at once.
+const defaultJobs = 4
+
+// registryOptions returns the options for transferring images, using the
+// profile's token for its registry and docker's credentials for others.
+func registryOptions(ctx context.Context, prof *Profile, jobs int, extra ...remote.Option) []remote.Option {
+	if jobs <= 0 {
+		jobs = defaultJobs
+	}
+
+	return remoteOptions(ctx, prof, append([]remote.Option{
+		remote.WithAuthFromKeychain(&profileKeychain{prof: prof}),
+		remote.WithJobs(jobs),
+	}, extra...)...)
+}
+
+// loadSource opens an image to push. Directories are read as OCI layouts, a
+// layout holding a single image or index pushes just that. Files are read as
+// tarballs as written by docker save, where sourceTag picks the image when
+// there are several.
+func loadSource(path, sourceTag string) (remote.Taggable, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !st.IsDir() {
+		var tag *name.Tag
+
+		if sourceTag != "" {
+			t, err := name.NewTag(sourceTag)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid source tag %s", sourceTag)
+			}
+
+			tag = &t
+		}
+
+		img, err := tarball.ImageFromPath(path, tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading image tarball %s", path)
+		}
+
+		return img, nil
+	}
+
+	idx, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading OCI layout %s", path)
+	}
+
+	im, err := idx.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	var descs []gv1.Descriptor
+
+	for _, d := range im.Manifests {
+		if sourceTag == "" || d.Annotations[refNameAnnotation] == sourceTag {
+			descs = append(descs, d)
+		}
+	}
+
+	switch {
+	case len(descs) == 0 && sourceTag != "":
+		return nil, fmt.Errorf("no image named %s in %s", sourceTag, path)
+	case len(descs) == 0:
+		return nil, fmt.Errorf("OCI layout %s is empty", path)
+	case len(descs) > 1:
+		return idx, nil
+	}
+
+	if descs[0].MediaType.IsIndex() {
+		return idx.ImageIndex(descs[0].Digest)
+	}
+
+	return idx.Image(descs[0].Digest)
+}
+
+type transferResult struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+	Path      string `json:"path,omitempty"`
+
+	pulled bool
+}
+
+func (r *transferResult) WriteText(w io.Writer) error {
+	if r.pulled {
+		_, err := fmt.Fprintf(w, "Pulled %s@%s into %s\n", r.Reference, r.Digest, r.Path)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Pushed %s@%s\n", r.Reference, r.Digest)
+	return err
+}
+
+func (c *CLI) pushF(ctx context.Context, opts struct {
+	SourceTag string `long:"source-tag" description:"image to push from a tarball or OCI layout holding several"`
+	Jobs      int    `short:"j" long:"jobs" default:"4" description:"how many layers to upload at once"`
+
+	Pos struct {
+		Source string `positional-arg-name:"tarball|oci-layout-dir"`
+		Ref    string `positional-arg-name:"ref"`
+	} `positional-args:"yes"`
+}) (*transferResult, error) {
+	if opts.Pos.Source == "" || opts.Pos.Ref == "" {
+		return nil, fmt.Errorf("requires the image to push and the reference to push to as arguments")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := name.ParseReference(qualifyName(prof, opts.Pos.Ref))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference %s", opts.Pos.Ref)
+	}
+
+	src, err := loadSource(opts.Pos.Source, opts.SourceTag)
+	if err != nil {
+		return nil, err
+	}
+
+	statusf(ctx, "Pushing %s to %s...\n", opts.Pos.Source, ref)
+
+	p := newProgress("Uploading", 0)
+
+	ropts := registryOptions(ctx, prof, opts.Jobs, remote.WithProgress(p.updates()))
+
+	var digest gv1.Hash
+
+	switch v := src.(type) {
+	case gv1.ImageIndex:
+		err = remote.WriteIndex(ref, v, ropts...)
+		if err == nil {
+			digest, err = v.Digest()
+		}
+	case gv1.Image:
+		err = remote.Write(ref, v, ropts...)
+		if err == nil {
+			digest, err = v.Digest()
+		}
+	}
+
+	p.finish(err)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error pushing %s", ref)
+	}
+
+	return &transferResult{
+		Reference: ref.String(),
+		Digest:    digest.String(),
+	}, nil
+}
+
+func (c *CLI) pullF(ctx context.Context, opts struct {
+	Platform string `long:"platform" description:"only pull the image for this platform, eg linux/amd64"`
+
+	Pos struct {
+		Ref string `positional-arg-name:"ref"`
+		Dir string `positional-arg-name:"dir"`
+	} `positional-args:"yes"`
+}) (*transferResult, error) {
+	if opts.Pos.Ref == "" || opts.Pos.Dir == "" {
+		return nil, fmt.Errorf("requires the reference to pull and the directory to write it to as arguments")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := name.ParseReference(qualifyName(prof, opts.Pos.Ref))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference %s", opts.Pos.Ref)
+	}
+
+	var extra []remote.Option
+
+	if opts.Platform != "" {
+		plat, err := parsePlatform(opts.Platform)
+		if err != nil {
+			return nil, err
+		}
+
+		extra = append(extra, remote.WithPlatform(plat))
+	}
+
+	desc, err := remote.Get(ref, registryOptions(ctx, prof, 0, extra...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading manifest of %s", ref)
+	}
+
+	var (
+		annos   []layout.Option
+		matcher match.Matcher = func(gv1.Descriptor) bool { return false }
+	)
+
+	if tag, ok := ref.(name.Tag); ok {
+		annos = append(annos, layout.WithAnnotations(map[string]string{
+			refNameAnnotation: tag.TagStr(),
+		}))
+
+		matcher = match.Annotation(refNameAnnotation, tag.TagStr())
+	}
+
+	// Count the bytes coming off the wire against the size of everything
+	// the manifest references.
+	ti := &tagInfo{}
+
+	if opts.Platform == "" {
+		err = describeTag(ti, desc)
+	} else {
+		var img gv1.Image
+
+		img, err = desc.Image()
+		if err == nil {
+			ti.Size, _, err = imageSize(img)
+		}
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", ref)
+	}
+
+	path, err := openLayout(opts.Pos.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	statusf(ctx, "Pulling %s into %s...\n", ref, opts.Pos.Dir)
+
+	p := newProgress("Downloading", ti.Size)
+
+	ropts := registryOptions(ctx, prof, 0, append(extra,
+		remote.WithTransport(&countingTransport{base: registryTransport(ctx, prof), p: p}))...)
+
+	desc, err = remote.Get(ref, ropts...)
+	if err == nil {
+		if desc.MediaType.IsIndex() && opts.Platform == "" {
+			var idx gv1.ImageIndex
+
+			idx, err = desc.ImageIndex()
+			if err == nil {
+				err = path.ReplaceIndex(idx, matcher, annos...)
+			}
+		} else {
+			var img gv1.Image
+
+			img, err = desc.Image()
+			if err == nil {
+				err = path.ReplaceImage(img, matcher, annos...)
+			}
+		}
+	}
+
+	p.finish(err)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error pulling %s", ref)
+	}
+
+	return &transferResult{
+		Reference: ref.String(),
+		Digest:    desc.Digest.String(),
+		Path:      opts.Pos.Dir,
+		pulled:    true,
+	}, nil
+}
+
+// parsePlatform parses a platform given as os/arch[/variant].
+func parsePlatform(s string) (gv1.Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return gv1.Platform{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+
+	plat := gv1.Platform{
+		OS:           parts[0],
+		Architecture: parts[1],
+	}
+
+	if len(parts) == 3 {
+		plat.Variant = parts[2]
+	}
+
+	return plat, nil
+}
+
+// openLayout opens the OCI layout at dir, creating it if needed.
+func openLayout(dir string) (layout.Path, error) {
+	if _, err := os.Stat(filepath.Join(dir, "index.json")); err == nil {
+		return layout.FromPath(dir)
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return "", fmt.Errorf("%s is not empty and not an OCI layout", dir)
+	}
+
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating OCI layout in %s", dir)
+	}
+
+	return p, nil
+}
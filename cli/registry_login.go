@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+// podmanAuthPath returns the auth file shared by podman, buildah and skopeo.
+func podmanAuthPath() (string, error) {
+	if path := os.Getenv("REGISTRY_AUTH_FILE"); path != "" {
+		return path, nil
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "containers", "auth.json"), nil
+	}
+
+	return homedir.Expand("~/.config/containers/auth.json")
+}
+
+// writeAuthFile merges the credentials for server into a docker style auth
+// file at path.
+func writeAuthFile(path, server, token string) error {
+	af, err := loadAuthFile(path)
+	if err != nil {
+		return err
+	}
+
+	af.setAuth(server, tokenUser, token)
+
+	return af.save(path)
+}
+
+// writeContainerdHosts merges the credentials for server into the containerd
+// hosts.toml for it, within dir.
+func writeContainerdHosts(dir, server, token string) (string, error) {
+	path := filepath.Join(dir, server, "hosts.toml")
+
+	hosts := map[string]interface{}{}
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		_, err = toml.Decode(string(data), &hosts)
+		if err != nil {
+			return "", errors.Wrapf(err, "error parsing %s", path)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	url := "https://" + server
+
+	if _, ok := hosts["server"]; !ok {
+		hosts["server"] = url
+	}
+
+	section := func(m map[string]interface{}, key string) map[string]interface{} {
+		if sec, ok := m[key].(map[string]interface{}); ok {
+			return sec
+		}
+
+		sec := map[string]interface{}{}
+		m[key] = sec
+
+		return sec
+	}
+
+	host := section(section(hosts, "host"), url)
+
+	if _, ok := host["capabilities"]; !ok {
+		host["capabilities"] = []string{"pull", "resolve", "push"}
+	}
+
+	basic := base64.StdEncoding.EncodeToString([]byte(tokenUser + ":" + token))
+
+	section(host, "header")["authorization"] = "Basic " + basic
+
+	var buf bytes.Buffer
+
+	err = toml.NewEncoder(&buf).Encode(hosts)
+	if err != nil {
+		return "", err
+	}
+
+	return path, writeFileAtomic(path, buf.Bytes())
+}
+
+type registryLoginResult struct {
+	Target string `json:"target"`
+	Server string `json:"server"`
+	Path   string `json:"path"`
+}
+
+func (r *registryLoginResult) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Wrote credentials for %s to %s\n", r.Server, r.Path)
+	return err
+}
+
+func (c *CLI) registryLoginF(ctx context.Context, opts struct {
+	Target        string `short:"t" long:"target" default:"docker" choice:"docker" choice:"podman" choice:"containerd" choice:"file" description:"tool to write credentials for"`
+	File          string `short:"f" long:"file" description:"auth file to write when target is file"`
+	ContainerdDir string `long:"containerd-dir" default:"/etc/containerd/certs.d" description:"containerd registry host configuration directory"`
+}) (*registryLoginResult, error) {
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	server := prof.RegistryHost()
+
+	var path string
+
+	switch opts.Target {
+	case "docker":
+		path, err = dockerConfigPath()
+	case "podman":
+		path, err = podmanAuthPath()
+	case "file":
+		if opts.File == "" {
+			return nil, fmt.Errorf("auth file (-f) is required with target file")
+		}
+
+		path, err = homedir.Expand(opts.File)
+	case "containerd":
+		path, err = writeContainerdHosts(opts.ContainerdDir, server, prof.Token)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error writing containerd configuration")
+		}
+
+		return &registryLoginResult{Target: opts.Target, Server: server, Path: path}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeAuthFile(path, server, prof.Token)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error writing auth file")
+	}
+
+	return &registryLoginResult{Target: opts.Target, Server: server, Path: path}, nil
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lab47/labctl/types"
+)
+
+// formatSize renders a byte count using binary units.
+func formatSize(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// validateRepoName checks that name is in namespace/repo format.
+func validateRepoName(name string) error {
+	if name == "" {
+		return fmt.Errorf("requires repository name as argument")
+	}
+
+	if strings.Count(name, "/") != 1 {
+		return fmt.Errorf("name must be in namespace/repo format")
+	}
+
+	return nil
+}
+
+type repoInfo types.RepoInfo
+
+func (r *repoInfo) WriteText(w io.Writer) error {
+	visibility := "private"
+	if r.Public {
+		visibility = "public"
+	}
+
+	lastPushed := "never"
+	if r.LastPushedAt != nil {
+		lastPushed = r.LastPushedAt.Format(time.RFC3339)
+	}
+
+	_, err := fmt.Fprintf(w, "[repository]\n       name: %s\n visibility: %s\n       tags: %d\n       size: %s\n    created: %s\nlast-pushed: %s\n  immutable: %s\n",
+		r.Name,
+		visibility,
+		r.TotalTags,
+		formatSize(r.Size),
+		r.CreatedAt.Format(time.RFC3339),
+		lastPushed,
+		describeImmutable(r.ImmutableTags),
+	)
+	return err
+}
+
+type repoList types.ListRepos
+
+func (l *repoList) WriteText(w io.Writer) error {
+	if len(l.Repos) == 0 {
+		_, err := fmt.Fprintln(w, "No repositories.")
+		return err
+	}
+
+	for i := range l.Repos {
+		err := (*repoInfo)(&l.Repos[i]).WriteText(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *CLI) reposF(ctx context.Context, opts struct {
+	Namespace string `short:"n" long:"namespace" description:"only list repositories in this namespace"`
+}) (*repoList, error) {
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	list, err := apiClient(ctx, prof).Repos(ctx, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*repoList)(list), nil
+}
+
+func (c *CLI) describeRepoF(ctx context.Context, opts struct {
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*repoInfo, error) {
+	err := validateRepoName(opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	info, err := apiClient(ctx, prof).Repo(ctx, opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*repoInfo)(info), nil
+}
+
+func (c *CLI) deleteRepoF(ctx context.Context, opts struct {
+	Confirm string `long:"confirm" description:"name of the repository, to confirm deletion without being prompted"`
+
+	Pos struct {
+		Name string `positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}) (*repoResult, error) {
+	name := opts.Pos.Name
+
+	err := validateRepoName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	api := apiClient(ctx, prof)
+
+	info, err := api.Repo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.TotalTags > 0 {
+		statusf(ctx, "Deleting %s will also delete its %d tags!\n", name, info.TotalTags)
+	}
+
+	confirm := opts.Confirm
+	if confirm == "" {
+		confirm, err = readLine(fmt.Sprintf("Type the name of the repository (%s) to confirm deletion: ", name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if confirm != name {
+		return nil, fmt.Errorf("confirmation did not match the repository name, not deleting")
+	}
+
+	statusf(ctx, "Deleting repository...\n")
+
+	err = api.DeleteRepo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repoResult{Name: name, Deleted: true}, nil
+}
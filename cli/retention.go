@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lab47/labctl/types"
+)
+
+// retentionOptions are the flags that make up a tag retention policy.
+type retentionOptions struct {
+	KeepLast       *int     `long:"keep-last" description:"keep only the N most recently pushed tags"`
+	KeepMatching   []string `long:"keep-matching" description:"always keep tags matching this regular expression, may be repeated"`
+	ExpireUntagged *int     `long:"expire-untagged-days" description:"delete manifests that have had no tag for N days"`
+	KeepSigned     bool     `long:"keep-signed" description:"never delete manifests that have a signature"`
+}
+
+// policy returns the policy described by the flags, or nil if none were set.
+func (o *retentionOptions) policy() (*types.RetentionPolicy, error) {
+	var (
+		p   types.RetentionPolicy
+		set bool
+	)
+
+	if o.KeepLast != nil {
+		if *o.KeepLast < 0 {
+			return nil, fmt.Errorf("--keep-last must not be negative")
+		}
+
+		p.KeepLast = o.KeepLast
+		set = true
+	}
+
+	for _, expr := range o.KeepMatching {
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid --keep-matching expression %q: %s", expr, err)
+		}
+
+		p.KeepMatching = append(p.KeepMatching, expr)
+		set = true
+	}
+
+	if o.ExpireUntagged != nil {
+		if *o.ExpireUntagged <= 0 {
+			return nil, fmt.Errorf("--expire-untagged-days must be positive")
+		}
+
+		p.ExpireUntaggedDays = o.ExpireUntagged
+		set = true
+	}
+
+	if o.KeepSigned {
+		keep := true
+		p.KeepSigned = &keep
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+
+	return &p, nil
+}
+
+// describePolicy summarizes a retention policy in a line.
+func describePolicy(p *types.RetentionPolicy) string {
+	var parts []string
+
+	if p.KeepLast != nil {
+		parts = append(parts, fmt.Sprintf("keep last %d tags", *p.KeepLast))
+	}
+
+	for _, expr := range p.KeepMatching {
+		parts = append(parts, fmt.Sprintf("keep tags matching /%s/", expr))
+	}
+
+	if p.ExpireUntaggedDays != nil {
+		parts = append(parts, fmt.Sprintf("expire untagged manifests after %d days", *p.ExpireUntaggedDays))
+	}
+
+	if p.KeepSigned != nil && *p.KeepSigned {
+		parts = append(parts, "keep signed manifests")
+	}
+
+	if len(parts) == 0 {
+		return "none"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+type retentionPreview types.RetentionPreview
+
+func (r *retentionPreview) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Policy: %s\n", describePolicy(&r.Policy))
+
+	if len(r.Remove) == 0 {
+		_, err := fmt.Fprintf(w, "Nothing would be removed, %d manifests kept.\n", r.Kept)
+		return err
+	}
+
+	var total int64
+
+	fmt.Fprintf(w, "Would remove:\n")
+
+	for _, c := range r.Remove {
+		name := c.Tag
+		if name == "" {
+			name = "<untagged>"
+		}
+
+		pushed := "unknown"
+		if c.PushedAt != nil {
+			pushed = c.PushedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "  - %s %s (%s, pushed %s): %s\n", name, c.Digest, formatSize(c.Size), pushed, c.Reason)
+
+		total += c.Size
+	}
+
+	_, err := fmt.Fprintf(w, "%d manifests (%s) would be removed, %d kept.\n", len(r.Remove), formatSize(total), r.Kept)
+	return err
+}
+
+func (c *CLI) retentionPreviewF(ctx context.Context, opts struct {
+	Retention retentionOptions `group:"Retention Options"`
+
+	Pos struct {
+		Name string `positional-arg-name:"namespace/repo"`
+	} `positional-args:"yes"`
+}) (*retentionPreview, error) {
+	err := validateRepoName(opts.Pos.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := opts.Retention.policy()
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if prof.Token == "" {
+		return nil, errNotLoggedIn
+	}
+
+	if policy == nil {
+		statusf(ctx, "Previewing the current retention policy of %s...\n", opts.Pos.Name)
+	}
+
+	preview, err := apiClient(ctx, prof).PreviewRetention(ctx, opts.Pos.Name, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*retentionPreview)(preview), nil
+}
@@ -0,0 +1,487 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// ErrSecretNotFound is returned by a SecretStore when no secret is stored
+// under the requested key.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore keeps the tokens referenced by the configuration out of the
+// configuration file itself.
+type SecretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+const (
+	storePlaintext = "plaintext"
+	storeKeyring   = "keyring"
+	storePass      = "pass"
+	storeFile      = "file"
+)
+
+var secretStores = []string{storePlaintext, storeKeyring, storePass, storeFile}
+
+// defaultSecretStore returns the backend used when none was chosen: the
+// keyring when secret-tool and a session bus to reach it are available, and
+// otherwise plaintext, which is returned as "".
+func defaultSecretStore() string {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return ""
+	}
+
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return ""
+	}
+
+	return storeKeyring
+}
+
+// openSecretStore returns the store for the named backend. The plaintext
+// backend has no store, tokens remain in the configuration file.
+func openSecretStore(kind string) (SecretStore, error) {
+	switch kind {
+	case "", storePlaintext:
+		return nil, nil
+	case storeKeyring:
+		return &keyringStore{service: "lab47"}, nil
+	case storePass:
+		return &passStore{prefix: "lab47"}, nil
+	case storeFile:
+		path, err := configFile("secrets.enc")
+		if err != nil {
+			return nil, err
+		}
+
+		return &fileStore{path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown secret store '%s', must be one of: %s",
+			kind, strings.Join(secretStores, ", "))
+	}
+}
+
+// keyringStore stores secrets in the desktop keyring via the Secret Service
+// D-Bus API, using libsecret's secret-tool.
+type keyringStore struct {
+	service string
+}
+
+func (k *keyringStore) Get(key string) (string, error) {
+	var out bytes.Buffer
+
+	cmd := exec.Command("secret-tool", "lookup", "service", k.service, "key", key)
+	cmd.Stdout = &out
+
+	err := cmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok && out.Len() == 0 {
+			return "", ErrSecretNotFound
+		}
+
+		return "", errors.Wrapf(err, "error reading secret from keyring")
+	}
+
+	return out.String(), nil
+}
+
+func (k *keyringStore) Set(key, value string) error {
+	cmd := exec.Command("secret-tool", "store",
+		"--label", fmt.Sprintf("%s: %s", k.service, key),
+		"service", k.service, "key", key)
+	cmd.Stdin = strings.NewReader(value)
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "error writing secret to keyring")
+	}
+
+	return nil
+}
+
+func (k *keyringStore) Delete(key string) error {
+	err := exec.Command("secret-tool", "clear", "service", k.service, "key", key).Run()
+	if err != nil {
+		// secret-tool fails when there's nothing to clear, which is told
+		// apart from other failures by looking the secret up.
+		if _, ok := err.(*exec.ExitError); ok {
+			if _, gerr := k.Get(key); gerr == ErrSecretNotFound {
+				return ErrSecretNotFound
+			}
+		}
+
+		return errors.Wrapf(err, "error removing secret from keyring")
+	}
+
+	return nil
+}
+
+// passStore stores secrets with pass, the standard unix password manager.
+type passStore struct {
+	prefix string
+}
+
+func (p *passStore) entry(key string) string {
+	return p.prefix + "/" + key
+}
+
+func (p *passStore) Get(key string) (string, error) {
+	var out, stderr bytes.Buffer
+
+	cmd := exec.Command("pass", "show", p.entry(key))
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if strings.Contains(stderr.String(), "is not in the password store") {
+			return "", ErrSecretNotFound
+		}
+
+		return "", errors.Wrapf(err, "error reading secret from pass: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+func (p *passStore) Set(key, value string) error {
+	cmd := exec.Command("pass", "insert", "--multiline", "--force", p.entry(key))
+	cmd.Stdin = strings.NewReader(value + "\n")
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "error writing secret to pass")
+	}
+
+	return nil
+}
+
+func (p *passStore) Delete(key string) error {
+	var stderr bytes.Buffer
+
+	cmd := exec.Command("pass", "rm", "--force", p.entry(key))
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if strings.Contains(stderr.String(), "is not in the password store") {
+			return ErrSecretNotFound
+		}
+
+		return errors.Wrapf(err, "error removing secret from pass: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// fileStore stores secrets in a local file, encrypted with a key derived
+// from a passphrase. The passphrase is read from LAB47_PASSPHRASE or
+// prompted for on the controlling terminal.
+type fileStore struct {
+	path string
+
+	key     *[32]byte
+	salt    []byte
+	secrets map[string]string
+}
+
+type encryptedFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// readPassphrase returns LAB47_PASSPHRASE or else prompts on /dev/tty.
+// The terminal is used rather than stdin, which carries the protocol when
+// running as a docker credential helper.
+func readPassphrase(prompt string) ([]byte, error) {
+	if pass := os.Getenv("LAB47_PASSPHRASE"); pass != "" {
+		return []byte(pass), nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to prompt for passphrase, set LAB47_PASSPHRASE")
+	}
+
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	data, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading passphrase")
+	}
+
+	return data, nil
+}
+
+func (f *fileStore) deriveKey(salt []byte) error {
+	pass, err := readPassphrase("Enter passphrase for secrets file: ")
+	if err != nil {
+		return err
+	}
+
+	data, err := scrypt.Key(pass, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return err
+	}
+
+	var key [32]byte
+	copy(key[:], data)
+
+	f.key = &key
+	f.salt = salt
+
+	return nil
+}
+
+func (f *fileStore) load() error {
+	if f.secrets != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		salt := make([]byte, 16)
+
+		_, err = io.ReadFull(rand.Reader, salt)
+		if err != nil {
+			return err
+		}
+
+		err = f.deriveKey(salt)
+		if err != nil {
+			return err
+		}
+
+		f.secrets = map[string]string{}
+
+		return nil
+	}
+
+	var ef encryptedFile
+
+	err = json.Unmarshal(data, &ef)
+	if err != nil {
+		return errors.Wrapf(err, "error decoding secrets file")
+	}
+
+	if len(ef.Nonce) != 24 {
+		return fmt.Errorf("corrupt secrets file: %s", f.path)
+	}
+
+	err = f.deriveKey(ef.Salt)
+	if err != nil {
+		return err
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], ef.Nonce)
+
+	plain, ok := secretbox.Open(nil, ef.Data, &nonce, f.key)
+	if !ok {
+		return fmt.Errorf("unable to decrypt secrets file, wrong passphrase?")
+	}
+
+	var secrets map[string]string
+
+	err = json.Unmarshal(plain, &secrets)
+	if err != nil {
+		return errors.Wrapf(err, "error decoding secrets file")
+	}
+
+	if secrets == nil {
+		secrets = map[string]string{}
+	}
+
+	f.secrets = secrets
+
+	return nil
+}
+
+func (f *fileStore) save() error {
+	plain, err := json.Marshal(f.secrets)
+	if err != nil {
+		return err
+	}
+
+	var nonce [24]byte
+
+	_, err = io.ReadFull(rand.Reader, nonce[:])
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&encryptedFile{
+		Salt:  f.salt,
+		Nonce: nonce[:],
+		Data:  secretbox.Seal(nil, plain, &nonce, f.key),
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(f.path, data)
+}
+
+func (f *fileStore) Get(key string) (string, error) {
+	err := f.load()
+	if err != nil {
+		return "", err
+	}
+
+	val, ok := f.secrets[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+
+	return val, nil
+}
+
+func (f *fileStore) Set(key, value string) error {
+	err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if cur, ok := f.secrets[key]; ok && cur == value {
+		return nil
+	}
+
+	f.secrets[key] = value
+
+	return f.save()
+}
+
+func (f *fileStore) Delete(key string) error {
+	err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := f.secrets[key]; !ok {
+		return ErrSecretNotFound
+	}
+
+	delete(f.secrets, key)
+
+	return f.save()
+}
+
+type secretStoreResult struct {
+	Store string `json:"store"`
+}
+
+func (r *secretStoreResult) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Tokens are stored in: %s\n", r.Store)
+	return err
+}
+
+func (c *CLI) secretStoreF(ctx context.Context, opts struct {
+	Pos struct {
+		Store string `positional-arg-name:"store"`
+	} `positional-args:"yes"`
+}) (*secretStoreResult, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Pos.Store == "" {
+		kind := cfg.SecretStore
+		if kind == "" {
+			kind = storePlaintext
+		}
+
+		return &secretStoreResult{Store: kind}, nil
+	}
+
+	_, err = openSecretStore(opts.Pos.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := cfg.secretStore()
+	if err != nil {
+		return nil, err
+	}
+
+	statusf(ctx, "Moving tokens into the %s secret store...\n", opts.Pos.Store)
+
+	cfg.SecretStore = opts.Pos.Store
+	cfg.stored = nil
+
+	err = SaveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if old != nil && old != cfg.store {
+		for _, name := range cfg.ProfileNames() {
+			err = old.Delete(secretKey(name))
+			if err != nil && err != ErrSecretNotFound {
+				fmt.Fprintf(os.Stderr, "Unable to remove token for profile %s from previous store: %s\n", name, err)
+			}
+		}
+	}
+
+	return &secretStoreResult{Store: opts.Pos.Store}, nil
+}
+
+// writeFileAtomic writes data to path, readable only by the current user,
+// replacing any existing file in a single step.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0600)
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"fmt"
+	"io"
 	"net/url"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -21,29 +22,42 @@ import (
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
+type personalTokenResult struct {
+	JWT      string        `json:"jwt"`
+	Verified *oidc.IDToken `json:"verified,omitempty"`
+}
+
+func (r *personalTokenResult) WriteText(w io.Writer) error {
+	fmt.Fprintln(w, r.JWT)
+
+	if r.Verified != nil {
+		spew.Fdump(w, r.Verified)
+	}
+
+	return nil
+}
+
 func (c *CLI) personalToken(ctx context.Context, opts struct {
 	Validate bool `short:"V" long:"validate" description:"validate token for OIDC"`
-}) error {
+}) (*personalTokenResult, error) {
 	var req types.PersonalTokenRequest
 
-	cfg, err := LoadConfig()
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var ret types.PersonalTokenResponse
-
-	err = TokenPost(ctx, cfg.Account.Token, "https://allow.pub/api/v1/personal-token", &req, &ret)
+	ret, err := apiClient(ctx, prof).PersonalToken(ctx, &req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Println(ret.JWT)
+	result := &personalTokenResult{JWT: ret.JWT}
 
 	if opts.Validate {
-		prov, err := oidc.NewProvider(context.Background(), "https://allow.pub")
+		prov, err := oidc.NewProvider(ctx, "https://allow.pub")
 		if err != nil {
-			return err
+			return nil, err
 		}
 		ver := prov.Verifier(&oidc.Config{
 			SkipClientIDCheck: true,
@@ -51,34 +65,45 @@ func (c *CLI) personalToken(ctx context.Context, opts struct {
 
 		ot, err := ver.Verify(ctx, ret.JWT)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		spew.Dump(ot)
+		result.Verified = ot
 	}
+
+	return result, nil
+}
+
+type fulcioCertResult struct {
+	Certificate string `json:"certificate"`
+	SCT         string `json:"sct"`
+}
+
+func (r *fulcioCertResult) WriteText(w io.Writer) error {
+	fmt.Fprintln(w, r.Certificate)
+	fmt.Fprintln(w, r.SCT)
+
 	return nil
 }
 
 func (c *CLI) fulcioCert(ctx context.Context, opts struct {
 	Validate bool `short:"V" long:"validate" description:"validate token for OIDC"`
-}) error {
+}) (*fulcioCertResult, error) {
 	var req types.PersonalTokenRequest
 
-	cfg, err := LoadConfig()
+	_, prof, err := loadProfile(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var ret types.PersonalTokenResponse
-
-	err = TokenPost(ctx, cfg.Account.Token, "https://allow.pub/api/v1/personal-token", &req, &ret)
+	ret, err := apiClient(ctx, prof).PersonalToken(ctx, &req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	u, err := url.Parse(client.SigstorePublicServerURL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	fc := client.New(u)
@@ -87,33 +112,33 @@ func (c *CLI) fulcioCert(ctx context.Context, opts struct {
 
 	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	tok, err := jwt.ParseSigned(ret.JWT)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var claims jwt.Claims
 
 	err = tok.UnsafeClaimsWithoutVerification(&claims)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Sign the email address as part of the request
 	h := sha256.Sum256([]byte(claims.Subject))
 	proof, err := ecdsa.SignASN1(rand.Reader, priv, h[:])
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	bearerAuth := httptransport.BearerToken(ret.JWT)
 
 	content := strfmt.Base64(pubBytes)
 	signedChallenge := strfmt.Base64(proof)
-	params := operations.NewSigningCertParams()
+	params := operations.NewSigningCertParamsWithContext(ctx)
 	params.SetCertificateRequest(
 		&models.CertificateRequest{
 			PublicKey: &models.CertificateRequestPublicKey{
@@ -126,15 +151,15 @@ func (c *CLI) fulcioCert(ctx context.Context, opts struct {
 
 	resp, err := fc.Operations.SigningCert(params, bearerAuth)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// split the cert and the chain
 	// certBlock, chainPem := pem.Decode([]byte(resp.Payload))
 	// certPem := pem.EncodeToMemory(certBlock)
 
-	fmt.Println(resp.Payload)
-	fmt.Println(resp.SCT.String())
-
-	return nil
+	return &fulcioCertResult{
+		Certificate: resp.Payload,
+		SCT:         resp.SCT.String(),
+	}, nil
 }
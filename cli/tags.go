@@ -0,0 +1,670 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+)
+
+// cosignSuffixes are the tag suffixes cosign uses to attach signatures,
+// attestations and SBOMs to a digest.
+var cosignSuffixes = []string{".sig", ".att", ".sbom"}
+
+// isCosignTag reports whether tag holds data cosign attached to a digest
+// rather than an image.
+func isCosignTag(tag string) bool {
+	if !strings.HasPrefix(tag, "sha256-") {
+		return false
+	}
+
+	for _, suf := range cosignSuffixes {
+		if strings.HasSuffix(tag, suf) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cosignTag returns the tag cosign uses for data of the given kind (one of
+// cosignSuffixes) attached to digest.
+func cosignTag(digest gv1.Hash, suffix string) string {
+	return digest.Algorithm + "-" + digest.Hex + suffix
+}
+
+// parseAge parses a duration, additionally accepting a number of days such
+// as 30d.
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q, expected a duration such as 72h or 30d", s)
+	}
+
+	return d, nil
+}
+
+type tagInfo struct {
+	Tag       string     `json:"tag"`
+	Digest    string     `json:"digest,omitempty"`
+	MediaType string     `json:"media_type,omitempty"`
+	Size      int64      `json:"size,omitempty"`
+	Pushed    *time.Time `json:"pushed_at,omitempty"`
+	Platforms []string   `json:"platforms,omitempty"`
+	Signed    bool       `json:"signed"`
+}
+
+func platformString(p *gv1.Platform) string {
+	if p == nil {
+		return "unknown"
+	}
+
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+
+	return s
+}
+
+// imageSize sums the size of the image's config and layers.
+func imageSize(img gv1.Image) (int64, *gv1.ConfigFile, error) {
+	man, err := img.Manifest()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size := man.Config.Size
+	for _, l := range man.Layers {
+		size += l.Size
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return size, cfg, nil
+}
+
+// describeTag fills in the details of what tag points to.
+func describeTag(ti *tagInfo, desc *remote.Descriptor) error {
+	ti.Digest = desc.Digest.String()
+	ti.MediaType = string(desc.MediaType)
+
+	switch {
+	case desc.MediaType.IsIndex():
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+
+		im, err := idx.IndexManifest()
+		if err != nil {
+			return err
+		}
+
+		for _, m := range im.Manifests {
+			ti.Platforms = append(ti.Platforms, platformString(m.Platform))
+
+			if !m.MediaType.IsImage() {
+				ti.Size += m.Size
+				continue
+			}
+
+			img, err := idx.Image(m.Digest)
+			if err != nil {
+				return err
+			}
+
+			size, _, err := imageSize(img)
+			if err != nil {
+				return err
+			}
+
+			ti.Size += size
+		}
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return err
+		}
+
+		size, cfg, err := imageSize(img)
+		if err != nil {
+			return err
+		}
+
+		ti.Size = size
+
+		if cfg.OS != "" {
+			ti.Platforms = []string{cfg.OS + "/" + cfg.Architecture}
+		}
+	default:
+		ti.Size = desc.Size
+	}
+
+	return nil
+}
+
+// tagFilter selects tags by glob pattern and age.
+type tagFilter struct {
+	patterns  []string
+	olderThan time.Duration
+	now       time.Time
+}
+
+func newTagFilter(patterns []string, olderThan string) (*tagFilter, error) {
+	f := &tagFilter{
+		patterns: patterns,
+		now:      time.Now(),
+	}
+
+	for _, pat := range patterns {
+		if _, err := path.Match(pat, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", pat)
+		}
+	}
+
+	if olderThan != "" {
+		d, err := parseAge(olderThan)
+		if err != nil {
+			return nil, err
+		}
+
+		f.olderThan = d
+	}
+
+	return f, nil
+}
+
+// matchName reports whether tag matches any of the patterns, or there are
+// none.
+func (f *tagFilter) matchName(tag string) bool {
+	if len(f.patterns) == 0 {
+		return true
+	}
+
+	for _, pat := range f.patterns {
+		if ok, _ := path.Match(pat, tag); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAge reports whether ti was pushed before the cutoff. Tags without a
+// known push time never match, so they're not deleted by accident.
+func (f *tagFilter) matchAge(ti *tagInfo) bool {
+	if f.olderThan == 0 {
+		return true
+	}
+
+	return ti.Pushed != nil && f.now.Sub(*ti.Pushed) > f.olderThan
+}
+
+// pushTimes returns when each tag of repo was last pushed. Only the API
+// knows, so it's empty for repositories on other registries or when not
+// logged in.
+func pushTimes(ctx context.Context, prof *Profile, repo name.Repository) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+
+	if prof.Token == "" || repo.RegistryStr() != prof.RegistryHost() {
+		return times, nil
+	}
+
+	list, err := apiClient(ctx, prof).Tags(ctx, repo.RepositoryStr())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading push times of %s", repo)
+	}
+
+	for _, t := range list.Tags {
+		times[t.Name] = t.PushedAt
+	}
+
+	return times, nil
+}
+
+// listTags returns the tags of repo matching the filter, leaving out those
+// cosign uses unless withCosign is set. Details are fetched for each tag if
+// requested.
+func listTags(ctx context.Context, prof *Profile, repo name.Repository, f *tagFilter, details, withCosign bool) ([]*tagInfo, error) {
+	ropts := remoteOptions(ctx, prof, remote.WithAuth(profileAuth(prof)))
+
+	tags, err := remote.List(repo, ropts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing tags of %s", repo)
+	}
+
+	sort.Strings(tags)
+
+	all := map[string]bool{}
+	for _, t := range tags {
+		all[t] = true
+	}
+
+	var pushed map[string]time.Time
+
+	if details || f.olderThan != 0 {
+		pushed, err = pushTimes(ctx, prof, repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*tagInfo
+
+	for _, t := range tags {
+		if (!withCosign && isCosignTag(t)) || !f.matchName(t) {
+			continue
+		}
+
+		ti := &tagInfo{Tag: t}
+
+		if at, ok := pushed[t]; ok {
+			ti.Pushed = &at
+		}
+
+		if !f.matchAge(ti) {
+			continue
+		}
+
+		if details {
+			desc, err := remote.Get(repo.Tag(t), ropts...)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading manifest of %s", t)
+			}
+
+			err = describeTag(ti, desc)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading details of %s", t)
+			}
+
+			ti.Signed = all[cosignTag(desc.Digest, ".sig")]
+		}
+
+		result = append(result, ti)
+	}
+
+	return result, nil
+}
+
+// tagsByDigest returns every tag of repo, and the tags pointing at each
+// manifest, leaving out those cosign uses.
+func tagsByDigest(repo name.Repository, ropts []remote.Option) (map[string]bool, map[gv1.Hash][]string, error) {
+	tags, err := remote.List(repo, ropts...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error listing tags of %s", repo)
+	}
+
+	sort.Strings(tags)
+
+	all := map[string]bool{}
+	via := map[gv1.Hash][]string{}
+
+	for _, t := range tags {
+		all[t] = true
+
+		if isCosignTag(t) {
+			continue
+		}
+
+		desc, err := remote.Head(repo.Tag(t), ropts...)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error reading manifest of %s", t)
+		}
+
+		via[desc.Digest] = append(via[desc.Digest], t)
+	}
+
+	return all, via, nil
+}
+
+// parseRepo parses a repository given as namespace/repo or with a registry.
+func parseRepo(prof *Profile, s string) (name.Repository, error) {
+	repo, err := name.NewRepository(qualifyName(prof, s))
+	if err != nil {
+		return repo, errors.Wrapf(err, "invalid repository %s", s)
+	}
+
+	return repo, nil
+}
+
+type tagList []*tagInfo
+
+func (l tagList) WriteText(w io.Writer) error {
+	if len(l) == 0 {
+		_, err := fmt.Fprintln(w, "No tags.")
+		return err
+	}
+
+	for _, ti := range l {
+		pushed := "unknown"
+		if ti.Pushed != nil {
+			pushed = ti.Pushed.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "[tag]\n      name: %s\n    digest: %s\n      size: %s\n    pushed: %s\n platforms: %s\n    signed: %t\n",
+			ti.Tag,
+			ti.Digest,
+			formatSize(ti.Size),
+			pushed,
+			strings.Join(ti.Platforms, ", "),
+			ti.Signed,
+		)
+	}
+
+	return nil
+}
+
+func (c *CLI) tagsF(ctx context.Context, opts struct {
+	Match     []string `short:"m" long:"match" description:"only show tags matching this glob, may be repeated"`
+	OlderThan string   `long:"older-than" description:"only show tags last pushed longer ago than this, eg 72h or 30d; tags whose push time is unknown, such as on other registries, never match"`
+	All       bool     `short:"a" long:"all" description:"include the tags cosign uses for signatures and attestations"`
+
+	Pos struct {
+		Repo string `positional-arg-name:"namespace/repo"`
+	} `positional-args:"yes"`
+}) (tagList, error) {
+	if opts.Pos.Repo == "" {
+		return nil, fmt.Errorf("requires repository as argument")
+	}
+
+	f, err := newTagFilter(opts.Match, opts.OlderThan)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := parseRepo(prof, opts.Pos.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return listTags(ctx, prof, repo, f, true, opts.All)
+}
+
+type deleteResult struct {
+	Repository string   `json:"repository"`
+	DryRun     bool     `json:"dry_run,omitempty"`
+	Deleted    []string `json:"deleted"`
+
+	// Untagged are the tags removed along with the manifests they point
+	// at without having been selected.
+	Untagged []string `json:"untagged,omitempty"`
+}
+
+func (r *deleteResult) WriteText(w io.Writer) error {
+	if len(r.Deleted) == 0 {
+		_, err := fmt.Fprintln(w, "Nothing matched, nothing deleted.")
+		return err
+	}
+
+	if r.DryRun {
+		fmt.Fprintf(w, "Would delete from %s:\n", r.Repository)
+	} else {
+		fmt.Fprintf(w, "Deleted from %s:\n", r.Repository)
+	}
+
+	for _, d := range r.Deleted {
+		fmt.Fprintf(w, "  - %s\n", d)
+	}
+
+	if len(r.Untagged) > 0 {
+		fmt.Fprintf(w, "Tags removed with them that weren't selected:\n")
+
+		for _, t := range r.Untagged {
+			fmt.Fprintf(w, "  - %s\n", t)
+		}
+	}
+
+	return nil
+}
+
+// deleteOptions are the flags shared by the commands deleting tags or
+// manifests.
+type deleteOptions struct {
+	OlderThan string `long:"older-than" description:"only delete tags last pushed longer ago than this, eg 72h or 30d; tags whose push time is unknown, such as on other registries, never match"`
+	DryRun    bool   `long:"dry-run" description:"show what would be deleted without deleting anything"`
+}
+
+func (c *CLI) untagF(ctx context.Context, opts struct {
+	Delete deleteOptions `group:"Delete Options"`
+
+	Pos struct {
+		Repo     string   `positional-arg-name:"namespace/repo"`
+		Patterns []string `positional-arg-name:"tag-glob"`
+	} `positional-args:"yes"`
+}) (*deleteResult, error) {
+	if opts.Pos.Repo == "" {
+		return nil, fmt.Errorf("requires repository as argument")
+	}
+
+	if len(opts.Pos.Patterns) == 0 && opts.Delete.OlderThan == "" {
+		return nil, fmt.Errorf("requires tags (globs are allowed) or --older-than to select what to untag")
+	}
+
+	f, err := newTagFilter(opts.Pos.Patterns, opts.Delete.OlderThan)
+	if err != nil {
+		return nil, err
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := parseRepo(prof, opts.Pos.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := listTags(ctx, prof, repo, f, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &deleteResult{
+		Repository: repo.String(),
+		DryRun:     opts.Delete.DryRun,
+	}
+
+	ropts := remoteOptions(ctx, prof, remote.WithAuth(profileAuth(prof)))
+
+	for _, ti := range tags {
+		if !opts.Delete.DryRun {
+			statusf(ctx, "Untagging %s...\n", ti.Tag)
+
+			err = remote.Delete(repo.Tag(ti.Tag), ropts...)
+			if err != nil {
+				return result, errors.Wrapf(err, "error untagging %s", ti.Tag)
+			}
+		}
+
+		result.Deleted = append(result.Deleted, ti.Tag)
+	}
+
+	return result, nil
+}
+
+func (c *CLI) deleteManifestF(ctx context.Context, opts struct {
+	Delete deleteOptions `group:"Delete Options"`
+
+	KeepSignatures bool `long:"keep-signatures" description:"don't delete the signatures and attestations attached to the manifests"`
+	Yes            bool `short:"y" long:"yes" description:"don't ask before removing tags that weren't selected but point at a deleted manifest"`
+
+	Pos struct {
+		Repo     string   `positional-arg-name:"namespace/repo[@digest]"`
+		Patterns []string `positional-arg-name:"tag-glob"`
+	} `positional-args:"yes"`
+}) (*deleteResult, error) {
+	if opts.Pos.Repo == "" {
+		return nil, fmt.Errorf("requires repository as argument")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		repo     name.Repository
+		digests  []gv1.Hash
+		selected = map[string]bool{}
+	)
+
+	if idx := strings.IndexByte(opts.Pos.Repo, '@'); idx != -1 {
+		if len(opts.Pos.Patterns) > 0 || opts.Delete.OlderThan != "" {
+			return nil, fmt.Errorf("tags and --older-than can't be used when deleting a digest")
+		}
+
+		dig, err := name.NewDigest(qualifyName(prof, opts.Pos.Repo))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid digest reference %s", opts.Pos.Repo)
+		}
+
+		h, err := gv1.NewHash(dig.DigestStr())
+		if err != nil {
+			return nil, err
+		}
+
+		repo = dig.Context()
+		digests = append(digests, h)
+	} else {
+		if len(opts.Pos.Patterns) == 0 && opts.Delete.OlderThan == "" {
+			return nil, fmt.Errorf("requires a digest, tags (globs are allowed) or --older-than to select what to delete")
+		}
+
+		f, err := newTagFilter(opts.Pos.Patterns, opts.Delete.OlderThan)
+		if err != nil {
+			return nil, err
+		}
+
+		repo, err = parseRepo(prof, opts.Pos.Repo)
+		if err != nil {
+			return nil, err
+		}
+
+		tags, err := listTags(ctx, prof, repo, f, true, false)
+		if err != nil {
+			return nil, err
+		}
+
+		seen := map[gv1.Hash]bool{}
+
+		for _, ti := range tags {
+			h, err := gv1.NewHash(ti.Digest)
+			if err != nil {
+				return nil, err
+			}
+
+			if !seen[h] {
+				seen[h] = true
+				digests = append(digests, h)
+			}
+
+			selected[ti.Tag] = true
+		}
+	}
+
+	ropts := remoteOptions(ctx, prof, remote.WithAuth(profileAuth(prof)))
+
+	result := &deleteResult{
+		Repository: repo.String(),
+		DryRun:     opts.Delete.DryRun,
+	}
+
+	if len(digests) == 0 {
+		return result, nil
+	}
+
+	existing, via, err := tagsByDigest(repo, ropts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting a manifest removes every tag pointing at it, so those that
+	// weren't selected are called out before anything is deleted.
+	for _, h := range digests {
+		for _, t := range via[h] {
+			if !selected[t] {
+				result.Untagged = append(result.Untagged, t)
+			}
+		}
+	}
+
+	if len(result.Untagged) > 0 && !opts.Delete.DryRun && !opts.Yes {
+		answer, err := readLine(fmt.Sprintf("Deleting these manifests also removes tags that weren't selected: %s. Continue? [y/N] ",
+			strings.Join(result.Untagged, ", ")))
+		if err != nil {
+			return nil, err
+		}
+
+		if answer != "y" && answer != "yes" {
+			return nil, fmt.Errorf("not confirmed, not deleting")
+		}
+	}
+
+	del := func(ref name.Reference, desc string) error {
+		if !opts.Delete.DryRun {
+			statusf(ctx, "Deleting %s...\n", desc)
+
+			err := remote.Delete(ref, ropts...)
+			if err != nil {
+				return errors.Wrapf(err, "error deleting %s", desc)
+			}
+		}
+
+		result.Deleted = append(result.Deleted, desc)
+
+		return nil
+	}
+
+	for _, h := range digests {
+		desc := h.String()
+		if tags := via[h]; len(tags) > 0 {
+			desc += " (" + strings.Join(tags, ", ") + ")"
+		}
+
+		err = del(repo.Digest(h.String()), desc)
+		if err != nil {
+			return result, err
+		}
+
+		for _, suf := range cosignSuffixes {
+			t := cosignTag(h, suf)
+
+			if opts.KeepSignatures || !existing[t] {
+				continue
+			}
+
+			err = del(repo.Tag(t), t)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	gv1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/lab47/labctl/pkg/fulcioroots"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -19,6 +23,63 @@ import (
 	"github.com/sigstore/sigstore/pkg/signature/payload"
 )
 
+// remoteOptions returns the options common to all registry requests made on
+// behalf of the running command. The profile's transport retries idempotent
+// requests; uploads, which aren't, are retried by go-containerregistry as a
+// whole, as many times as the profile allows. go-containerregistry also
+// retries requests that fail with a temporary network error on its own, which
+// can't be turned off.
+func remoteOptions(ctx context.Context, prof *Profile, extra ...remote.Option) []remote.Option {
+	return append([]remote.Option{
+		remote.WithContext(ctx),
+		remote.WithTransport(registryTransport(ctx, prof)),
+		remote.WithRetryBackoff(uploadBackoff(prof.retries())),
+	}, extra...)
+}
+
+// uploadBackoff returns how go-containerregistry waits between attempts at an
+// upload, with retries being the number of attempts after the first.
+func uploadBackoff(retries int) remote.Backoff {
+	if retries < 0 {
+		retries = 0
+	}
+
+	return remote.Backoff{
+		Duration: time.Second,
+		Factor:   3,
+		Jitter:   0.1,
+		Steps:    retries + 1,
+	}
+}
+
+// profileAuth returns the credentials for the profile's registry, which are
+// anonymous when not logged in.
+func profileAuth(prof *Profile) authn.Authenticator {
+	if prof.Token == "" {
+		return authn.Anonymous
+	}
+
+	return &authn.Basic{
+		Username: tokenUser,
+		Password: prof.Token,
+	}
+}
+
+// qualifyName prefixes a repository or reference given as namespace/repo
+// with the profile's registry. Names that already start with a registry
+// host are returned as is.
+func qualifyName(prof *Profile, s string) string {
+	if idx := strings.IndexByte(s, '/'); idx != -1 {
+		first := s[:idx]
+
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			return s
+		}
+	}
+
+	return prof.RegistryHost() + "/" + s
+}
+
 func (c *CLI) fetchSigF(ctx context.Context, opts struct {
 	Username string `short:"u" description:"username to authenticate with"`
 	Password string `short:"p" description:"password associated with username"`
@@ -26,13 +87,21 @@ func (c *CLI) fetchSigF(ctx context.Context, opts struct {
 	Pos struct {
 		Name string `positional-arg-name:"name" required:"true"`
 	} `positional-args:"yes" required:"true"`
-}) error {
+}) (*verificationResult, error) {
 	ref, err := name.ParseReference(opts.Pos.Name)
 	if err != nil {
-		return errors.Wrapf(err, "error parse reference")
+		return nil, errors.Wrapf(err, "error parse reference")
 	}
 
-	var ropts []remote.Option
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	ropts := remoteOptions(ctx, prof)
 
 	if opts.Password != "" {
 		ropts = append(ropts, remote.WithAuth(&authn.Basic{
@@ -50,13 +119,12 @@ func (c *CLI) fetchSigF(ctx context.Context, opts struct {
 
 	sigs, bundled, err := cosign.VerifySignatures(ctx, ref, &co)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	PrintVerificationHeader(opts.Pos.Name, &co, bundled)
-	PrintVerification(opts.Pos.Name, sigs)
 
-	return nil
+	return newVerificationResult(opts.Pos.Name, sigs)
 }
 
 func PrintVerificationHeader(imgRef string, co *cosign.CheckOpts, bundleVerified bool) {
@@ -72,32 +140,63 @@ func PrintVerificationHeader(imgRef string, co *cosign.CheckOpts, bundleVerified
 	}
 }
 
-// PrintVerification logs details about the verification to stdout
-func PrintVerification(imgRef string, verified []oci.Signature) {
+type verifiedSignature struct {
+	Subject       string `json:"subject,omitempty"`
+	Issuer        string `json:"issuer,omitempty"`
+	ServiceSigned bool   `json:"service_signed"`
+}
+
+type verificationResult struct {
+	Reference  string              `json:"reference"`
+	Signatures []verifiedSignature `json:"signatures"`
+}
+
+// newVerificationResult gathers the details about the verified signatures.
+func newVerificationResult(imgRef string, verified []oci.Signature) (*verificationResult, error) {
+	result := &verificationResult{Reference: imgRef}
+
 	for _, sig := range verified {
+		var vs verifiedSignature
+
 		if cert, err := sig.Cert(); err == nil && cert != nil {
-			fmt.Println("✅ subject:", sigs.CertSubject(cert))
-			if issuerURL := sigs.CertIssuerExtension(cert); issuerURL != "" {
-				fmt.Println("✅ issuer:", issuerURL)
-			}
+			vs.Subject = sigs.CertSubject(cert)
+			vs.Issuer = sigs.CertIssuerExtension(cert)
 		}
 
 		p, err := sig.Payload()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error fetching payload: %v", err)
-			return
+			return nil, errors.Wrapf(err, "error fetching payload")
 		}
 
 		ss := payload.SimpleContainerImage{}
 		if err := json.Unmarshal(p, &ss); err != nil {
-			fmt.Println("error decoding the payload:", err.Error())
-			return
+			return nil, errors.Wrapf(err, "error decoding the payload")
 		}
 
-		if ss.Optional["signed-by"] == "vcr.pub" {
-			fmt.Fprintln(os.Stderr, "✅ vcr.pub service side signature")
+		vs.ServiceSigned = ss.Optional["signed-by"] == "vcr.pub"
+
+		result.Signatures = append(result.Signatures, vs)
+	}
+
+	return result, nil
+}
+
+func (r *verificationResult) WriteText(w io.Writer) error {
+	for _, sig := range r.Signatures {
+		if sig.Subject != "" {
+			fmt.Fprintln(w, "✅ subject:", sig.Subject)
+		}
+
+		if sig.Issuer != "" {
+			fmt.Fprintln(w, "✅ issuer:", sig.Issuer)
+		}
+
+		if sig.ServiceSigned {
+			fmt.Fprintln(w, "✅ vcr.pub service side signature")
 		}
 	}
+
+	return nil
 }
 
 func (c *CLI) fetchManifestF(ctx context.Context, opts struct {
@@ -107,14 +206,21 @@ func (c *CLI) fetchManifestF(ctx context.Context, opts struct {
 	Pos struct {
 		Name string `positional-arg-name:"name" required:"true"`
 	} `positional-args:"yes" required:"true"`
-}) error {
-
+}) (*manifestResult, error) {
 	ref, err := name.ParseReference(opts.Pos.Name)
 	if err != nil {
-		return errors.Wrapf(err, "error parse reference")
+		return nil, errors.Wrapf(err, "error parse reference")
 	}
 
-	var ropts []remote.Option
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	ropts := remoteOptions(ctx, prof)
 
 	if opts.Password != "" {
 		ropts = append(ropts, remote.WithAuth(&authn.Basic{
@@ -125,41 +231,82 @@ func (c *CLI) fetchManifestF(ctx context.Context, opts struct {
 
 	desc, err := remote.Get(ref, ropts...)
 	if err != nil {
-		return errors.Wrapf(err, "error reading manifest")
+		return nil, errors.Wrapf(err, "error reading manifest")
 	}
 
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-
-	fmt.Println("Descriptor:")
-	enc.Encode(map[string]interface{}{
-		"annotations": desc.Annotations,
-		"digest":      desc.Digest,
-		"media-type":  desc.MediaType,
-	})
+	result := &manifestResult{
+		Descriptor: map[string]interface{}{
+			"annotations": desc.Annotations,
+			"digest":      desc.Digest,
+			"media-type":  desc.MediaType,
+		},
+	}
 
-	fmt.Println("Manifest:")
 	switch desc.MediaType {
 	case "application/vnd.docker.distribution.manifest.list.v2+json", v1.MediaTypeImageIndex:
 		var man v1.Index
 
 		err = json.Unmarshal(desc.Manifest, &man)
 		if err != nil {
-			return errors.Wrapf(err, "error parsing manifest")
+			return nil, errors.Wrapf(err, "error parsing manifest")
 		}
-		return enc.Encode(&man)
+
+		result.Manifest = &man
 	case "application/vnd.docker.distribution.manifest.v2+json", v1.MediaTypeImageManifest:
 
 		var man v1.Manifest
 
 		err = json.Unmarshal(desc.Manifest, &man)
 		if err != nil {
-			return errors.Wrapf(err, "error parsing manifest")
+			return nil, errors.Wrapf(err, "error parsing manifest")
 		}
-		return enc.Encode(&man)
+
+		result.Manifest = &man
 	default:
-		return errors.Errorf("unknown media-type: %s", desc.MediaType)
+		return nil, errors.Errorf("unknown media-type: %s", desc.MediaType)
+	}
+
+	// Immutability is a vcr setting, so it's only known for tags in the
+	// profile's registry.
+	if tag, ok := ref.(name.Tag); ok && tag.RegistryStr() == prof.RegistryHost() && prof.Token != "" {
+		info, err := apiClient(ctx, prof).Repo(ctx, tag.RepositoryStr())
+		if err == nil {
+			locked, reason := tagLocked(info.ImmutableTags, tag.TagStr())
+
+			result.Locked = &locked
+			result.LockReason = reason
+		}
+	}
+
+	return result, nil
+}
+
+type manifestResult struct {
+	Descriptor map[string]interface{} `json:"descriptor"`
+	Manifest   interface{}            `json:"manifest"`
+
+	// Locked is set when it's known whether the tag is immutable.
+	Locked     *bool  `json:"locked,omitempty"`
+	LockReason string `json:"lock_reason,omitempty"`
+}
+
+func (r *manifestResult) WriteText(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if r.Locked != nil {
+		if *r.Locked {
+			fmt.Fprintf(w, "Tag: locked, it can't be moved or deleted (%s)\n", r.LockReason)
+		} else {
+			fmt.Fprintln(w, "Tag: mutable")
+		}
 	}
+
+	fmt.Fprintln(w, "Descriptor:")
+	enc.Encode(r.Descriptor)
+
+	fmt.Fprintln(w, "Manifest:")
+	return enc.Encode(r.Manifest)
 }
 
 func (c *CLI) fetchConfigF(ctx context.Context, opts struct {
@@ -169,37 +316,51 @@ func (c *CLI) fetchConfigF(ctx context.Context, opts struct {
 	Pos struct {
 		Name string `positional-arg-name:"name" required:"true"`
 	} `positional-args:"yes" required:"true"`
-}) error {
+}) (*configResult, error) {
 	ref, err := name.ParseReference(opts.Pos.Name)
 	if err != nil {
-		return errors.Wrapf(err, "error parse reference")
+		return nil, errors.Wrapf(err, "error parse reference")
+	}
+
+	_, prof, err := loadProfile(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	desc, err := remote.Get(ref, remote.WithAuth(&authn.Basic{
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	desc, err := remote.Get(ref, remoteOptions(ctx, prof, remote.WithAuth(&authn.Basic{
 		Username: opts.Username,
 		Password: opts.Password,
-	}))
+	}))...)
 	if err != nil {
-		return errors.Wrapf(err, "error reading manifest")
+		return nil, errors.Wrapf(err, "error reading manifest")
 	}
 
 	img, err := desc.Image()
 	if err != nil {
-		return errors.Wrapf(err, "error parsing image information")
+		return nil, errors.Wrapf(err, "error parsing image information")
 	}
 
 	cfg, err := img.ConfigFile()
 	if err != nil {
-		return errors.Wrapf(err, "error parsing config information")
+		return nil, errors.Wrapf(err, "error parsing config information")
 	}
 
 	err = json.Unmarshal(desc.Manifest, cfg)
 	if err != nil {
-		return errors.Wrapf(err, "error parsing manifest")
+		return nil, errors.Wrapf(err, "error parsing manifest")
 	}
 
-	enc := json.NewEncoder(os.Stdout)
+	return (*configResult)(cfg), nil
+}
+
+type configResult gv1.ConfigFile
+
+func (r *configResult) WriteText(w io.Writer) error {
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 
-	return enc.Encode(cfg)
+	return enc.Encode(r)
 }
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lab47/labctl/cli"
+)
+
+// docker-credential-labctl is invoked by docker as
+// `docker-credential-labctl <action>` and serves credentials from the
+// labctl configuration.
+func main() {
+	c, err := cli.NewCLI(append([]string{"credential-helper"}, os.Args[1:]...))
+	if err != nil {
+		fmt.Printf("Error setting up CLI: %s\n", err)
+		os.Exit(1)
+	}
+
+	code, err := c.Run()
+	if err != nil {
+		fmt.Printf("Error running CLI: %s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(code)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lab47/labctl/types"
+)
+
+// CreateAccount creates a new account, reserving its initial namespace, and
+// returns a token for it.
+func (c *Client) CreateAccount(ctx context.Context, info *types.AccountInfo) (*types.AccountResponse, error) {
+	var resp types.AccountResponse
+
+	err := c.do(ctx, "POST", "/api/v1/account", nil, info, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Login exchanges an email and password for a token.
+func (c *Client) Login(ctx context.Context, email, password string) (*types.AccountResponse, error) {
+	hdrs := http.Header{}
+	basicAuth(hdrs, email, password)
+
+	var resp types.AccountResponse
+
+	err := c.do(ctx, "GET", "/api/v1/token", hdrs, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// WhoAmI returns the identity of the token along with its expiry and scopes.
+func (c *Client) WhoAmI(ctx context.Context) (*types.WhoAmIResponse, error) {
+	var resp types.WhoAmIResponse
+
+	err := c.get(ctx, "/api/v1/whoami", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// RevokeToken invalidates the client's token on the server.
+func (c *Client) RevokeToken(ctx context.Context) error {
+	return c.delete(ctx, "/api/v1/token", nil)
+}
+
+// ChangePassword changes the account's password, authenticating with the
+// current one. The server may rotate the token as part of the change, in
+// which case the new token is returned.
+func (c *Client) ChangePassword(ctx context.Context, email, password, newPassword string) (*types.AccountResponse, error) {
+	hdrs := http.Header{}
+	basicAuth(hdrs, email, password)
+
+	var resp types.AccountResponse
+
+	err := c.do(ctx, "PUT", "/api/v1/account/password", hdrs, &types.AccountInfo{
+		Email:       email,
+		Password:    password,
+		NewPassword: newPassword,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// RequestPasswordReset emails a password reset token to the account.
+func (c *Client) RequestPasswordReset(ctx context.Context, email string) error {
+	return c.do(ctx, "POST", "/api/v1/account/reset", nil, &types.PasswordResetRequest{
+		Email: email,
+	}, nil)
+}
+
+// ResetPassword sets a new password using the token from a reset email,
+// returning a token for the account.
+func (c *Client) ResetPassword(ctx context.Context, token, newPassword string) (*types.AccountResponse, error) {
+	var resp types.AccountResponse
+
+	err := c.do(ctx, "PUT", "/api/v1/account/reset", nil, &types.PasswordResetConfirm{
+		Token:       token,
+		NewPassword: newPassword,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// ExchangeToken trades an OIDC ID token from a third party issuer for a
+// short lived token, as allowed by the trust policy of namespace.
+func (c *Client) ExchangeToken(ctx context.Context, namespace, idToken string) (*types.TokenExchangeResponse, error) {
+	var resp types.TokenExchangeResponse
+
+	err := c.do(ctx, "POST", "/api/v1/token/exchange", nil, &types.TokenExchangeRequest{
+		Namespace:    namespace,
+		SubjectToken: idToken,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
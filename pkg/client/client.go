@@ -0,0 +1,170 @@
+// Package client is a Go client for the lab47 API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBaseURL is the location of the public lab47 API.
+const DefaultBaseURL = "https://svc.lab47.dev"
+
+// TokenUser is the username used when authenticating with a token over
+// basic auth, both against the API and the registry.
+const TokenUser = "cytoken"
+
+// Client makes requests to the lab47 API on behalf of a token.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+
+	// Timeout bounds each request, 0 for no deadline beyond the context's.
+	Timeout time.Duration
+}
+
+// New returns a client for the API at baseURL, authenticating with token.
+// A nil http.Client uses http.DefaultClient.
+func New(baseURL, token string, hc *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: hc,
+	}
+}
+
+// RemoteError is the error reported by the API. It's available as the
+// Remote field of the *Error returned for a failed request.
+type RemoteError struct {
+	Code   int    `json:"code"`
+	ErrorS string `json:"error"`
+}
+
+func (r *RemoteError) Error() string {
+	return fmt.Sprintf("remote error: %s (%d)", r.ErrorS, r.Code)
+}
+
+type Status struct {
+	Status string `json:"status"`
+}
+
+func basicAuth(hdrs http.Header, user, pass string) {
+	hdrs.Set("Authorization",
+		"Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+}
+
+func (c *Client) tokenAuth() http.Header {
+	hdrs := http.Header{}
+	basicAuth(hdrs, TokenUser, c.Token)
+	return hdrs
+}
+
+// do performs a request against path, which is relative to BaseURL unless
+// it's a full URL, encoding val as the JSON body and decoding the response
+// into ret.
+func (c *Client) do(ctx context.Context, method, path string, hdrs http.Header, val interface{}, ret interface{}) error {
+	var r io.Reader
+
+	if val != nil {
+		body, err := json.Marshal(val)
+		if err != nil {
+			return errors.Wrapf(err, "error marshaling request")
+		}
+
+		r = bytes.NewReader(body)
+	}
+
+	var url string
+
+	if strings.HasPrefix(path, "https://") {
+		url = path
+	} else {
+		url = c.BaseURL + path
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range hdrs {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return &Error{
+			Kind: KindNetwork,
+			Err:  errors.Wrapf(err, "error posting to: %s", path),
+		}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		if resp.Header.Get("Content-Type") == "application/json" {
+			var er RemoteError
+
+			err = json.NewDecoder(resp.Body).Decode(&er)
+			if err != nil {
+				return errors.Wrapf(err, "error decoding response")
+			}
+
+			return newResponseError(resp.StatusCode, &er)
+		}
+
+		return newResponseError(resp.StatusCode, nil)
+	}
+
+	if ret == nil {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(ret)
+	if err != nil {
+		return errors.Wrapf(err, "error decoding response")
+	}
+
+	return nil
+}
+
+func (c *Client) get(ctx context.Context, path string, ret interface{}) error {
+	return c.do(ctx, "GET", path, c.tokenAuth(), nil, ret)
+}
+
+func (c *Client) post(ctx context.Context, path string, req, ret interface{}) error {
+	return c.do(ctx, "POST", path, c.tokenAuth(), req, ret)
+}
+
+func (c *Client) put(ctx context.Context, path string, req, ret interface{}) error {
+	return c.do(ctx, "PUT", path, c.tokenAuth(), req, ret)
+}
+
+func (c *Client) delete(ctx context.Context, path string, ret interface{}) error {
+	return c.do(ctx, "DELETE", path, c.tokenAuth(), nil, ret)
+}
@@ -0,0 +1,21 @@
+package client
+
+import (
+	"context"
+
+	"github.com/lab47/labctl/types"
+)
+
+// AddCredit starts a purchase of credits for a namespace. The returned URL
+// is where the payment is completed. It's never retried, as a retry could
+// start a second payment.
+func (c *Client) AddCredit(ctx context.Context, req *types.CreditAddRequest) (*types.CreditAddResponse, error) {
+	var resp types.CreditAddResponse
+
+	err := c.put(ctx, "/api/v1/credit/add", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
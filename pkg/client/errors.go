@@ -0,0 +1,147 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Kind classifies why a request to the API failed.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindUnauthenticated
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindInsufficientCredit
+	KindNetwork
+	KindValidation
+	KindServer
+)
+
+var kindNames = map[Kind]string{
+	KindUnknown:            "unknown",
+	KindUnauthenticated:    "unauthenticated",
+	KindForbidden:          "forbidden",
+	KindNotFound:           "not found",
+	KindConflict:           "conflict",
+	KindInsufficientCredit: "insufficient credit",
+	KindNetwork:            "network",
+	KindValidation:         "validation",
+	KindServer:             "server",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// KindForStatus returns the kind of error indicated by an HTTP status code.
+func KindForStatus(status int) Kind {
+	switch status {
+	case http.StatusUnauthorized:
+		return KindUnauthenticated
+	case http.StatusForbidden:
+		return KindForbidden
+	case http.StatusNotFound, http.StatusGone:
+		return KindNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return KindConflict
+	case http.StatusPaymentRequired:
+		return KindInsufficientCredit
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return KindValidation
+	}
+
+	if status >= 500 {
+		return KindServer
+	}
+
+	return KindUnknown
+}
+
+// Error is returned when a request to the API fails.
+type Error struct {
+	Kind Kind
+
+	// Status is the HTTP status of the response, 0 when there was none.
+	Status int
+
+	// Remote is the error reported by the API, if it sent one.
+	Remote *RemoteError
+
+	// Err is the underlying error for failures before a response arrived.
+	Err error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Remote != nil:
+		return e.Remote.Error()
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return fmt.Sprintf("Unexpected status: %d", e.Status)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	if e.Remote != nil {
+		return e.Remote
+	}
+
+	return e.Err
+}
+
+// Format passes %+v through to the underlying error so that stack traces
+// are shown when requested.
+func (e *Error) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') && e.Err != nil {
+		fmt.Fprintf(s, "%+v", e.Err)
+		return
+	}
+
+	fmt.Fprint(s, e.Error())
+}
+
+// newResponseError builds the error for a failed response, classifying it by
+// the code the API reported or else the HTTP status.
+func newResponseError(status int, remote *RemoteError) *Error {
+	kind := KindUnknown
+
+	if remote != nil {
+		kind = KindForStatus(remote.Code)
+	}
+
+	if kind == KindUnknown {
+		kind = KindForStatus(status)
+	}
+
+	return &Error{
+		Kind:   kind,
+		Status: status,
+		Remote: remote,
+	}
+}
+
+// KindOf returns the kind of the first *Error in err's chain.
+func KindOf(err error) Kind {
+	var ce *Error
+
+	if errors.As(err, &ce) {
+		return ce.Kind
+	}
+
+	return KindUnknown
+}
+
+// IsKind reports whether err is an API error of the given kind.
+func IsKind(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/lab47/labctl/types"
+)
+
+func machineAccountsPath(namespace string) string {
+	return fmt.Sprintf("/api/v1/namespace/%s/machine-account", namespace)
+}
+
+func machineAccountPath(namespace, name string) string {
+	return machineAccountsPath(namespace) + "/" + url.PathEscape(name)
+}
+
+// CreateMachineAccount creates a machine account in namespace, returning its
+// token. It's never retried, as a retry could create a second account.
+func (c *Client) CreateMachineAccount(ctx context.Context, namespace string, req *types.MachineAccountCreateRequest) (*types.MachineAccountCreateResponse, error) {
+	var resp types.MachineAccountCreateResponse
+
+	err := c.put(ctx, machineAccountsPath(namespace), req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// MachineAccounts lists the machine accounts in namespace.
+func (c *Client) MachineAccounts(ctx context.Context, namespace string) (*types.ListMachineAccounts, error) {
+	var resp types.ListMachineAccounts
+
+	err := c.get(ctx, machineAccountsPath(namespace), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// MachineAccount returns the details of a single machine account.
+func (c *Client) MachineAccount(ctx context.Context, namespace, name string) (*types.MachineAccountInfo, error) {
+	var resp types.MachineAccountInfo
+
+	err := c.get(ctx, machineAccountPath(namespace, name), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// RotateMachineAccount issues a new token for a machine account,
+// invalidating the previous one.
+func (c *Client) RotateMachineAccount(ctx context.Context, namespace, name string) (*types.MachineAccountCreateResponse, error) {
+	var resp types.MachineAccountCreateResponse
+
+	err := c.post(ctx, machineAccountPath(namespace, name)+"/rotate", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// RevokeMachineAccount deletes a machine account along with its token.
+func (c *Client) RevokeMachineAccount(ctx context.Context, namespace, name string) error {
+	return c.delete(ctx, machineAccountPath(namespace, name), nil)
+}
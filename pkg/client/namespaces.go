@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/lab47/labctl/types"
+)
+
+func namespacePath(name string) string {
+	return fmt.Sprintf("/api/v1/namespace/%s", name)
+}
+
+// Namespaces lists the namespaces the token has access to.
+func (c *Client) Namespaces(ctx context.Context) (*types.ListNamespaces, error) {
+	var ln types.ListNamespaces
+
+	err := c.get(ctx, "/api/v1/namespaces", &ln)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ln, nil
+}
+
+// CreateNamespace reserves a new namespace, owned by the token's account.
+func (c *Client) CreateNamespace(ctx context.Context, info *types.NamespaceInfo) error {
+	return c.post(ctx, namespacePath(info.Name), info, nil)
+}
+
+// Namespace returns the details of a namespace, including its repositories.
+func (c *Client) Namespace(ctx context.Context, name string) (*types.NamespaceDetails, error) {
+	var nd types.NamespaceDetails
+
+	err := c.get(ctx, namespacePath(name), &nd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nd, nil
+}
+
+// DeleteNamespace deletes a namespace. The server refuses to delete one that
+// still has repositories unless force is set, in which case they're deleted
+// too.
+func (c *Client) DeleteNamespace(ctx context.Context, name string, force bool) error {
+	path := namespacePath(name)
+	if force {
+		path += "?force=true"
+	}
+
+	return c.delete(ctx, path, nil)
+}
+
+// UpdateNamespaceMetadata merges metadata into the namespace's metadata.
+// Keys with an empty value are removed.
+func (c *Client) UpdateNamespaceMetadata(ctx context.Context, name string, metadata map[string]string) (*types.NamespaceInfo, error) {
+	var resp types.NamespaceInfo
+
+	err := c.do(ctx, "PATCH", namespacePath(name)+"/metadata", c.tokenAuth(), &types.NamespaceInfo{
+		Name:     name,
+		Metadata: metadata,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+func memberPath(namespace, email string) string {
+	return namespacePath(namespace) + "/members/" + url.PathEscape(email)
+}
+
+// Members lists the members of a namespace, including pending invitations.
+func (c *Client) Members(ctx context.Context, namespace string) (*types.ListNamespaceMembers, error) {
+	var resp types.ListNamespaceMembers
+
+	err := c.get(ctx, namespacePath(namespace)+"/members", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// InviteMember invites email to join a namespace with role. The returned
+// code is also emailed to the invitee.
+func (c *Client) InviteMember(ctx context.Context, namespace string, req *types.NamespaceInviteRequest) (*types.NamespaceInviteResponse, error) {
+	var resp types.NamespaceInviteResponse
+
+	err := c.post(ctx, namespacePath(namespace)+"/members", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// SetMemberRole changes the role of a member of a namespace.
+func (c *Client) SetMemberRole(ctx context.Context, namespace, email, role string) error {
+	return c.put(ctx, memberPath(namespace, email), &types.NamespaceRoleRequest{Role: role}, nil)
+}
+
+// RemoveMember removes a member from a namespace, or withdraws their
+// invitation.
+func (c *Client) RemoveMember(ctx context.Context, namespace, email string) error {
+	return c.delete(ctx, memberPath(namespace, email), nil)
+}
+
+// JoinNamespace accepts an invitation to a namespace.
+func (c *Client) JoinNamespace(ctx context.Context, code string) (*types.NamespaceJoinResponse, error) {
+	var resp types.NamespaceJoinResponse
+
+	err := c.post(ctx, "/api/v1/namespaces/join", &types.NamespaceJoinRequest{Code: code}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+
+	"github.com/lab47/labctl/types"
+	"github.com/pkg/errors"
+)
+
+// OAuthClientID identifies labctl to the authorization server.
+const OAuthClientID = "labctl"
+
+const (
+	GrantAuthCode   = "authorization_code"
+	GrantDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+var (
+	// ErrAuthorizationPending is returned by PollDeviceLogin while the user
+	// has yet to approve the login.
+	ErrAuthorizationPending = errors.New("authorization pending")
+
+	// ErrSlowDown is returned by PollDeviceLogin when the polling interval
+	// should be increased.
+	ErrSlowDown = errors.New("polling too quickly")
+)
+
+// AuthorizeURL returns the page a browser is sent to in order to approve a
+// login, which redirects back to redirectURI with a code.
+func (c *Client) AuthorizeURL() string {
+	return c.BaseURL + "/oauth/authorize"
+}
+
+// ExchangeAuthCode trades the code from a browser login, along with the
+// PKCE verifier used to request it, for a token.
+func (c *Client) ExchangeAuthCode(ctx context.Context, code, verifier, redirectURI string) (*types.OAuthTokenResponse, error) {
+	var resp types.OAuthTokenResponse
+
+	err := c.do(ctx, "POST", "/api/v1/oauth/token", nil, &types.OAuthTokenRequest{
+		GrantType:    GrantAuthCode,
+		ClientID:     OAuthClientID,
+		Code:         code,
+		CodeVerifier: verifier,
+		RedirectURI:  redirectURI,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// StartDeviceLogin begins a login that is approved on another device.
+func (c *Client) StartDeviceLogin(ctx context.Context) (*types.DeviceCodeResponse, error) {
+	var resp types.DeviceCodeResponse
+
+	err := c.do(ctx, "POST", "/api/v1/oauth/device", nil, &types.DeviceCodeRequest{
+		ClientID: OAuthClientID,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// PollDeviceLogin checks whether a device login has been approved. It
+// returns ErrAuthorizationPending or ErrSlowDown while waiting on the user.
+func (c *Client) PollDeviceLogin(ctx context.Context, deviceCode string) (*types.OAuthTokenResponse, error) {
+	var resp types.OAuthTokenResponse
+
+	err := c.do(ctx, "POST", "/api/v1/oauth/token", nil, &types.OAuthTokenRequest{
+		GrantType:  GrantDeviceCode,
+		ClientID:   OAuthClientID,
+		DeviceCode: deviceCode,
+	}, &resp)
+	if err != nil {
+		var ce *Error
+
+		if errors.As(err, &ce) && ce.Remote != nil {
+			switch ce.Remote.ErrorS {
+			case "authorization_pending":
+				return nil, ErrAuthorizationPending
+			case "slow_down":
+				return nil, ErrSlowDown
+			}
+		}
+
+		return nil, err
+	}
+
+	return &resp, nil
+}
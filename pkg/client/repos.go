@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/lab47/labctl/types"
+)
+
+func repoPath(name string) string {
+	return fmt.Sprintf("/vcr/v1/repo/%s", name)
+}
+
+// CreateRepo creates a repository, named in namespace/repo format.
+func (c *Client) CreateRepo(ctx context.Context, name string) error {
+	return c.post(ctx, repoPath(name), nil, nil)
+}
+
+// UpdateRepoSettings applies settings to a repository. Unset fields are left
+// unchanged.
+func (c *Client) UpdateRepoSettings(ctx context.Context, name string, settings *types.RepoSettingsApply) error {
+	return c.put(ctx, repoPath(name)+"/update-settings", settings, nil)
+}
+
+// Repos lists the repositories the token has access to, limited to those in
+// namespace if it's set.
+func (c *Client) Repos(ctx context.Context, namespace string) (*types.ListRepos, error) {
+	path := "/vcr/v1/repos"
+	if namespace != "" {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+
+	var resp types.ListRepos
+
+	err := c.get(ctx, path, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Repo returns the details of a repository.
+func (c *Client) Repo(ctx context.Context, name string) (*types.RepoInfo, error) {
+	var resp types.RepoInfo
+
+	err := c.get(ctx, repoPath(name), &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// Tags lists the tags of a repository along with when each was last pushed.
+func (c *Client) Tags(ctx context.Context, name string) (*types.ListTags, error) {
+	var resp types.ListTags
+
+	err := c.get(ctx, repoPath(name)+"/tags", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// DeleteRepo deletes a repository along with all of its tags and manifests.
+func (c *Client) DeleteRepo(ctx context.Context, name string) error {
+	return c.delete(ctx, repoPath(name), nil)
+}
+
+// PreviewRetention reports what the retention policy would remove from a
+// repository. A nil policy previews the one currently set on the repository.
+func (c *Client) PreviewRetention(ctx context.Context, name string, policy *types.RetentionPolicy) (*types.RetentionPreview, error) {
+	var resp types.RetentionPreview
+
+	err := c.post(ctx, repoPath(name)+"/retention/preview", policy, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+
+	"github.com/lab47/labctl/types"
+)
+
+// PersonalTokenURL is where personal tokens are issued.
+const PersonalTokenURL = "https://allow.pub/api/v1/personal-token"
+
+// PersonalToken requests a token identifying the current user, usable as an
+// OIDC identity token.
+func (c *Client) PersonalToken(ctx context.Context, req *types.PersonalTokenRequest) (*types.PersonalTokenResponse, error) {
+	var resp types.PersonalTokenResponse
+
+	err := c.post(ctx, PersonalTokenURL, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
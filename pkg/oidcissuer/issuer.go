@@ -0,0 +1,160 @@
+// Package oidcissuer is a minimal OIDC identity provider for exercising
+// token exchange locally, standing in for CI issuers such as GitHub Actions.
+// It serves discovery and keys over plain HTTP on the loopback interface and
+// mints ID tokens with whatever claims it's asked to.
+package oidcissuer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const keyID = "oidcissuer"
+
+// Issuer is a running stand-in OIDC issuer.
+type Issuer struct {
+	// URL is the issuer identifier, which is also where it's served.
+	URL string
+
+	key    *rsa.PrivateKey
+	signer jose.Signer
+	srv    *http.Server
+}
+
+// Claims are the claims placed into a minted token. Extra holds any
+// non-standard claims, like the repository claims GitHub includes.
+type Claims struct {
+	Subject  string
+	Audience []string
+	TTL      time.Duration
+	Extra    map[string]interface{}
+}
+
+// New generates a signing key for an issuer that isn't served yet. Serve
+// its Handler and set URL to where it's served, as with httptest:
+//
+//	iss, _ := oidcissuer.New()
+//	srv := httptest.NewServer(iss.Handler())
+//	iss.URL = srv.URL
+func New() (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating signing key")
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issuer{
+		key:    key,
+		signer: signer,
+	}, nil
+}
+
+// Start generates a signing key and serves a new issuer on a random port.
+func Start() (*Issuer, error) {
+	iss, err := New()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	iss.URL = fmt.Sprintf("http://%s", l.Addr())
+	iss.srv = &http.Server{Handler: iss.Handler()}
+
+	go iss.srv.Serve(l)
+
+	return iss, nil
+}
+
+// Handler returns the handler serving discovery and keys.
+func (i *Issuer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/keys", i.keys)
+
+	return mux
+}
+
+// Close stops serving an issuer created by Start.
+func (i *Issuer) Close() error {
+	if i.srv == nil {
+		return nil
+	}
+
+	return i.srv.Close()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                i.URL,
+		"jwks_uri":                              i.URL + "/keys",
+		"response_types_supported":              []string{"id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *Issuer) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{
+				Key:       &i.key.PublicKey,
+				KeyID:     keyID,
+				Algorithm: string(jose.RS256),
+				Use:       "sig",
+			},
+		},
+	})
+}
+
+// Token mints a signed ID token with the given claims. A zero TTL issues a
+// token valid for 5 minutes, a negative one an already expired token.
+func (i *Issuer) Token(c Claims) (string, error) {
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+
+	now := time.Now()
+
+	std := jwt.Claims{
+		Issuer:    i.URL,
+		Subject:   c.Subject,
+		Audience:  jwt.Audience(c.Audience),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now.Add(-time.Minute)),
+		Expiry:    jwt.NewNumericDate(now.Add(ttl)),
+	}
+
+	b := jwt.Signed(i.signer).Claims(std)
+
+	if len(c.Extra) > 0 {
+		b = b.Claims(c.Extra)
+	}
+
+	return b.CompactSerialize()
+}
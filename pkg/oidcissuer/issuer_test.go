@@ -0,0 +1,146 @@
+package oidcissuer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+func serve(t *testing.T) *Issuer {
+	t.Helper()
+
+	iss, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(iss.Handler())
+	t.Cleanup(srv.Close)
+
+	iss.URL = srv.URL
+
+	return iss
+}
+
+func verifier(t *testing.T, iss *Issuer, audience string) *oidc.IDTokenVerifier {
+	t.Helper()
+
+	prov, err := oidc.NewProvider(context.Background(), iss.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return prov.Verifier(&oidc.Config{ClientID: audience})
+}
+
+func TestDiscovery(t *testing.T) {
+	iss := serve(t)
+
+	resp, err := http.Get(iss.URL + "/.well-known/openid-configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Issuer != iss.URL {
+		t.Errorf("issuer is %q, expected %q", doc.Issuer, iss.URL)
+	}
+
+	if doc.JWKSURI != iss.URL+"/keys" {
+		t.Errorf("jwks_uri is %q", doc.JWKSURI)
+	}
+}
+
+func TestToken(t *testing.T) {
+	iss := serve(t)
+
+	raw, err := iss.Token(Claims{
+		Subject:  "repo:lab47/labctl:ref:refs/heads/main",
+		Audience: []string{"vcr.pub"},
+		Extra:    map[string]interface{}{"repository": "lab47/labctl"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idt, err := verifier(t, iss, "vcr.pub").Verify(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idt.Subject != "repo:lab47/labctl:ref:refs/heads/main" {
+		t.Errorf("subject is %q", idt.Subject)
+	}
+
+	var extra struct {
+		Repository string `json:"repository"`
+	}
+
+	err = idt.Claims(&extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if extra.Repository != "lab47/labctl" {
+		t.Errorf("repository claim is %q", extra.Repository)
+	}
+}
+
+func TestTokenRejected(t *testing.T) {
+	iss := serve(t)
+
+	expired, err := iss.Token(Claims{Subject: "ci", Audience: []string{"vcr.pub"}, TTL: -time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifier(t, iss, "vcr.pub").Verify(context.Background(), expired)
+	if err == nil {
+		t.Error("expired token was accepted")
+	}
+
+	other, err := iss.Token(Claims{Subject: "ci", Audience: []string{"other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifier(t, iss, "vcr.pub").Verify(context.Background(), other)
+	if err == nil {
+		t.Error("token for another audience was accepted")
+	}
+
+	// A token signed by a different issuer's key doesn't verify, even when
+	// it names this issuer.
+	imposter, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imposter.URL = iss.URL
+
+	forged, err := imposter.Token(Claims{Subject: "ci", Audience: []string{"vcr.pub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verifier(t, iss, "vcr.pub").Verify(context.Background(), forged)
+	if err == nil {
+		t.Error("token signed with another key was accepted")
+	}
+}
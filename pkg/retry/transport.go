@@ -0,0 +1,178 @@
+package retry
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetries    = 3
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+
+	// maxRetryAfter caps how long a server can ask us to wait.
+	maxRetryAfter = 2 * time.Minute
+)
+
+// Transport is an http.RoundTripper that retries requests which fail with a
+// network error or a transient status code, waiting with exponential backoff
+// and jitter between attempts. Only idempotent requests are retried, except
+// on 429 Too Many Requests where the server has not processed the request.
+// PUT is not assumed to be idempotent, since the API uses it to create
+// things; such requests opt in by sending an Idempotency-Key header.
+// Retry-After is honored on 429 and 503 responses.
+type Transport struct {
+	Base http.RoundTripper
+
+	Retries    int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewTransport returns a Transport wrapping base that retries up to retries
+// times with the default backoff.
+func NewTransport(base http.RoundTripper, retries int) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &Transport{
+		Base:       base,
+		Retries:    retries,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "DELETE":
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	val := resp.Header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+
+	var d time.Duration
+
+	if secs, err := strconv.Atoi(val); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(val); err == nil {
+		d = t.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if d < 0 {
+		d = 0
+	}
+
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+
+	return d, true
+}
+
+// backoff returns the delay before the given retry attempt, using full
+// jitter over an exponentially growing window.
+func (t *Transport) backoff(attempt int) time.Duration {
+	window := t.MinBackoff << uint(attempt)
+	if window <= 0 || window > t.MaxBackoff {
+		window = t.MaxBackoff
+	}
+
+	if window <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(window)))
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	canRewind := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		// A RoundTripper mustn't modify the request, so retries send a copy
+		// with a fresh body.
+		try := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			try = req.Clone(req.Context())
+			try.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(try)
+
+		if attempt >= t.Retries || !canRewind {
+			return resp, err
+		}
+
+		var wait time.Duration
+
+		switch {
+		case err != nil:
+			if !idempotent(req) || req.Context().Err() != nil {
+				return resp, err
+			}
+
+			wait = t.backoff(attempt)
+		case retryableStatus(resp.StatusCode):
+			if resp.StatusCode != http.StatusTooManyRequests && !idempotent(req) {
+				return resp, err
+			}
+
+			wait = t.backoff(attempt)
+
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if d, ok := retryAfter(resp, time.Now()); ok {
+					wait = d
+				}
+			}
+
+			// Drain a bit of the body so the connection can be reused.
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package retry
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBase answers every request with status, or fails with err, and records
+// what it was sent.
+type fakeBase struct {
+	status int
+	err    error
+
+	reqs   []*http.Request
+	bodies []string
+}
+
+func (f *fakeBase) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.reqs = append(f.reqs, req)
+
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		f.bodies = append(f.bodies, string(data))
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &http.Response{
+		StatusCode: f.status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func fastTransport(base http.RoundTripper, retries int) *Transport {
+	t := NewTransport(base, retries)
+	t.MinBackoff = time.Microsecond
+	t.MaxBackoff = time.Millisecond
+
+	return t
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		name     string
+		min, max time.Duration
+		attempt  int
+		window   time.Duration
+	}{
+		{"first", time.Second, time.Minute, 0, time.Second},
+		{"grows", time.Second, time.Minute, 3, 8 * time.Second},
+		{"capped", time.Second, 10 * time.Second, 5, 10 * time.Second},
+		{"overflow", time.Second, 10 * time.Second, 80, 10 * time.Second},
+		{"zero", 0, 0, 2, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tr := &Transport{MinBackoff: c.min, MaxBackoff: c.max}
+
+			for i := 0; i < 100; i++ {
+				d := tr.backoff(c.attempt)
+
+				if d < 0 || (c.window > 0 && d >= c.window) || (c.window == 0 && d != 0) {
+					t.Fatalf("backoff(%d) = %s, want in [0, %s)", c.attempt, d, c.window)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"missing", "", 0, false},
+		{"seconds", "7", 7 * time.Second, true},
+		{"date", now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{"past date", now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+		{"negative", "-5", 0, true},
+		{"capped", "3600", maxRetryAfter, true},
+		{"garbage", "soon", 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if c.header != "" {
+				resp.Header.Set("Retry-After", c.header)
+			}
+
+			got, ok := retryAfter(resp, now)
+			if got != c.want || ok != c.ok {
+				t.Fatalf("retryAfter(%q) = %s, %v, want %s, %v", c.header, got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestRoundTripAttempts(t *testing.T) {
+	netErr := errors.New("connection reset by peer")
+
+	cases := []struct {
+		name    string
+		method  string
+		key     string
+		status  int
+		err     error
+		retries int
+		want    int
+	}{
+		{"get 503", "GET", "", 503, nil, 3, 4},
+		{"head 502", "HEAD", "", 502, nil, 3, 4},
+		{"delete 500", "DELETE", "", 500, nil, 3, 4},
+		{"get network error", "GET", "", 0, netErr, 3, 4},
+		{"get 404", "GET", "", 404, nil, 3, 1},
+		{"get 200", "GET", "", 200, nil, 3, 1},
+		{"no retries", "GET", "", 503, nil, 0, 1},
+		{"post 503", "POST", "", 503, nil, 3, 1},
+		{"put 503", "PUT", "", 503, nil, 3, 1},
+		{"patch network error", "PATCH", "", 0, netErr, 3, 1},
+		{"post 429", "POST", "", 429, nil, 3, 4},
+		{"post 503 with key", "POST", "abc", 503, nil, 3, 4},
+		{"put network error with key", "PUT", "abc", 0, netErr, 2, 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			base := &fakeBase{status: c.status, err: c.err}
+
+			req, err := http.NewRequest(c.method, "http://example.com/v1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.key != "" {
+				req.Header.Set("Idempotency-Key", c.key)
+			}
+
+			resp, err := fastTransport(base, c.retries).RoundTrip(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if len(base.reqs) != c.want {
+				t.Fatalf("made %d attempts, want %d", len(base.reqs), c.want)
+			}
+
+			for _, r := range base.reqs {
+				if got := r.Header.Get("Idempotency-Key"); got != c.key {
+					t.Fatalf("Idempotency-Key = %q, want %q", got, c.key)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTripRewindsBody(t *testing.T) {
+	base := &fakeBase{status: 503}
+
+	req, err := http.NewRequest("PUT", "http://example.com/v1", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Idempotency-Key", "abc")
+	body := req.Body
+
+	resp, err := fastTransport(base, 2).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if len(base.bodies) != 3 {
+		t.Fatalf("made %d attempts, want 3", len(base.bodies))
+	}
+
+	for i, b := range base.bodies {
+		if b != "payload" {
+			t.Errorf("attempt %d sent %q, want %q", i+1, b, "payload")
+		}
+	}
+
+	if req.Body != body {
+		t.Error("the caller's request body was replaced")
+	}
+
+	for _, r := range base.reqs[1:] {
+		if r == req {
+			t.Error("a retry reused the caller's request")
+		}
+	}
+}
+
+func TestRoundTripUnrewindableBody(t *testing.T) {
+	base := &fakeBase{status: 503}
+
+	req, err := http.NewRequest("PUT", "http://example.com/v1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Idempotency-Key", "abc")
+	req.Body = ioutil.NopCloser(strings.NewReader("payload"))
+
+	resp, err := fastTransport(base, 3).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if len(base.reqs) != 1 {
+		t.Fatalf("made %d attempts, want 1", len(base.reqs))
+	}
+}
@@ -13,27 +13,114 @@ type AccountResponse struct {
 	Token string `json:"token"`
 }
 
+type TokenExchangeRequest struct {
+	Namespace    string `json:"namespace"`
+	SubjectToken string `json:"subject_token"`
+}
+
+type TokenExchangeResponse struct {
+	Token     string     `json:"token"`
+	Subject   string     `json:"subject"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetConfirm struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type WhoAmIResponse struct {
+	Email      string     `json:"email"`
+	Namespaces []string   `json:"namespaces"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Scopes     []string   `json:"scopes"`
+}
+
 type RepoDetails struct {
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 	TotalTags int       `json:"num_tags"`
 }
 
+type RepoInfo struct {
+	RepoDetails
+
+	Public       bool       `json:"public"`
+	Size         int64      `json:"size"`
+	LastPushedAt *time.Time `json:"last_pushed_at,omitempty"`
+
+	ImmutableTags *ImmutableTags `json:"immutable_tags,omitempty"`
+}
+
+type ListRepos struct {
+	Repos []RepoInfo `json:"repositories"`
+}
+
+type TagDetails struct {
+	Name     string    `json:"name"`
+	Digest   string    `json:"digest"`
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+type ListTags struct {
+	Tags []TagDetails `json:"tags"`
+}
+
 type NamespaceInfo struct {
 	Name     string            `json:"name"`
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type NamespaceDetails struct {
-	Name   string        `json:"name"`
-	Credit string        `json:"credit"`
-	Repos  []RepoDetails `json:"repositories"`
+	Name     string            `json:"name"`
+	Credit   string            `json:"credit"`
+	Repos    []RepoDetails     `json:"repositories"`
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type ListNamespaces struct {
 	Namespaces []NamespaceDetails `json:"namespaces"`
 }
 
+type NamespaceMember struct {
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	Pending   bool       `json:"pending,omitempty"`
+	InvitedAt *time.Time `json:"invited_at,omitempty"`
+	JoinedAt  *time.Time `json:"joined_at,omitempty"`
+}
+
+type ListNamespaceMembers struct {
+	Members []NamespaceMember `json:"members"`
+}
+
+type NamespaceInviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type NamespaceInviteResponse struct {
+	Code      string     `json:"code"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type NamespaceRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type NamespaceJoinRequest struct {
+	Code string `json:"code"`
+}
+
+type NamespaceJoinResponse struct {
+	Namespace string `json:"namespace"`
+	Role      string `json:"role"`
+}
+
 type CreditAddRequest struct {
 	Namespace string `json:"namespace"`
 	Credits   int64  `json:"credits"`
@@ -44,18 +131,77 @@ type CreditAddResponse struct {
 	URL string `json:"url"`
 }
 
+type MachineAccountScope struct {
+	Repository string   `json:"repository"`
+	Actions    []string `json:"actions"`
+}
+
+type MachineAccountRestrictions struct {
+	Scopes       []MachineAccountScope `json:"scopes,omitempty"`
+	AllowedIPs   []string              `json:"allowed_ips,omitempty"`
+	OIDCIssuer   string                `json:"oidc_issuer,omitempty"`
+	OIDCSubjects []string              `json:"oidc_subjects,omitempty"`
+}
+
 type MachineAccountCreateRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Write       bool   `json:"enable_write"`
+	TTL         int64  `json:"ttl,omitempty"`
+
+	MachineAccountRestrictions
 }
 
 type MachineAccountCreateResponse struct {
-	Token string `json:"tokn"`
+	Token     string     `json:"tokn"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type MachineAccountInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Write       bool       `json:"enable_write"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	MachineAccountRestrictions
+}
+
+type ListMachineAccounts struct {
+	MachineAccounts []MachineAccountInfo `json:"machine_accounts"`
+}
+
+type RetentionPolicy struct {
+	KeepLast           *int     `json:"keep_last,omitempty"`
+	KeepMatching       []string `json:"keep_matching,omitempty"`
+	ExpireUntaggedDays *int     `json:"expire_untagged_days,omitempty"`
+	KeepSigned         *bool    `json:"keep_signed,omitempty"`
+}
+
+type ImmutableTags struct {
+	All      bool     `json:"all,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
 }
 
 type RepoSettingsApply struct {
-	Public *bool `json:"public"`
+	Public        *bool            `json:"public"`
+	Retention     *RetentionPolicy `json:"retention,omitempty"`
+	ImmutableTags *ImmutableTags   `json:"immutable_tags,omitempty"`
+}
+
+type RetentionCandidate struct {
+	Tag      string     `json:"tag,omitempty"`
+	Digest   string     `json:"digest"`
+	Size     int64      `json:"size"`
+	PushedAt *time.Time `json:"pushed_at,omitempty"`
+	Reason   string     `json:"reason"`
+}
+
+type RetentionPreview struct {
+	Policy RetentionPolicy      `json:"policy"`
+	Remove []RetentionCandidate `json:"remove"`
+	Kept   int                  `json:"kept"`
 }
 
 type PersonalTokenRequest struct {
@@ -68,3 +214,30 @@ type PersonalTokenResponse struct {
 	JWT  string `json:"jwt,omitempty"`
 	X509 string `json:"x509,omitempty"`
 }
+
+type OAuthTokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	Code         string `json:"code,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+	DeviceCode   string `json:"device_code,omitempty"`
+}
+
+type OAuthTokenResponse struct {
+	Token string `json:"access_token"`
+	Email string `json:"email"`
+}
+
+type DeviceCodeRequest struct {
+	ClientID string `json:"client_id"`
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}